@@ -0,0 +1,325 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+func init() {
+	RegisterBackend(spectacleBackend{})
+	RegisterBackend(gnomeScreenshotBackend{})
+	RegisterBackend(flameshotBackend{})
+	RegisterBackend(grimBackend{})
+	RegisterBackend(xfceScreenshooterBackend{})
+	RegisterBackend(maimBackend{})
+	RegisterBackend(scrotBackend{})
+}
+
+// --- KDE Spectacle
+
+type spectacleBackend struct{}
+
+func (spectacleBackend) Name() string       { return "spectacle" }
+func (spectacleBackend) Sessions() []string { return []string{SessionWayland, SessionX11} }
+
+func (spectacleBackend) Available(r Runner) bool {
+	return haveBinaries(r, "spectacle")
+}
+
+func (spectacleBackend) args(req CaptureRequest) []string {
+	args := []string{"-n", "-b", "-o", req.OutputPath}
+	if req.Region {
+		args = append(args, "-r")
+	}
+	if req.Cursor {
+		args = append(args, "-p")
+	}
+	return args
+}
+
+func (b spectacleBackend) Capture(r Runner, req CaptureRequest) error {
+	if err := r.Run("spectacle", b.args(req)...); err != nil {
+		return fmt.Errorf("spectacle failed: %w", err)
+	}
+	return nil
+}
+
+// --- gnome-screenshot
+
+type gnomeScreenshotBackend struct{}
+
+func (gnomeScreenshotBackend) Name() string       { return "gnome-screenshot" }
+func (gnomeScreenshotBackend) Sessions() []string { return []string{SessionWayland} }
+
+func (gnomeScreenshotBackend) Available(r Runner) bool {
+	return haveBinaries(r, "gnome-screenshot")
+}
+
+func (gnomeScreenshotBackend) args(req CaptureRequest) []string {
+	if req.Cursor {
+		return []string{"-p", "-f", req.OutputPath}
+	}
+	return []string{"-f", req.OutputPath}
+}
+
+func (b gnomeScreenshotBackend) Capture(r Runner, req CaptureRequest) error {
+	// gnome-screenshot -a never writes the selection to disk on Wayland,
+	// the screenshot portal does the region selection instead
+	if req.Region {
+		portal := portalBackend{}
+		if !portal.Available(r) {
+			return fmt.Errorf("gnome-screenshot cannot save region screenshots on Wayland and xdg-desktop-portal is not available")
+		}
+		return portal.Capture(r, req)
+	}
+
+	if err := r.Run("gnome-screenshot", b.args(req)...); err != nil {
+		return fmt.Errorf("gnome-screenshot failed: %w", err)
+	}
+	return nil
+}
+
+// --- Flameshot
+
+type flameshotBackend struct{}
+
+func (flameshotBackend) Name() string       { return "flameshot" }
+func (flameshotBackend) Sessions() []string { return []string{SessionWayland, SessionX11} }
+
+func (flameshotBackend) Available(r Runner) bool {
+	return haveBinaries(r, "flameshot")
+}
+
+func (flameshotBackend) args(req CaptureRequest) []string {
+	if req.Region {
+		return []string{"gui", "--path", req.OutputPath}
+	}
+	return []string{"full", "--path", req.OutputPath}
+}
+
+func (b flameshotBackend) Capture(r Runner, req CaptureRequest) error {
+	if req.Cursor {
+		fmt.Fprintln(os.Stderr, "flameshot cannot include the mouse cursor, capturing without it")
+	}
+	if err := r.Run("flameshot", b.args(req)...); err != nil {
+		return fmt.Errorf("flameshot failed: %w", err)
+	}
+	return nil
+}
+
+// --- grim + slurp (wlroots)
+
+type grimBackend struct{}
+
+func (grimBackend) Name() string       { return "grim" }
+func (grimBackend) Sessions() []string { return []string{SessionWayland} }
+
+func (grimBackend) Available(r Runner) bool {
+	return haveBinaries(r, "grim")
+}
+
+// args builds the grim command line, geometry is slurp's "X,Y WxH" output
+func (grimBackend) args(req CaptureRequest, geometry string) []string {
+	var args []string
+	if req.Cursor {
+		args = append(args, "-c")
+	}
+	if geometry != "" {
+		args = append(args, "-g", geometry)
+	}
+	return append(args, req.OutputPath)
+}
+
+func (b grimBackend) Capture(r Runner, req CaptureRequest) error {
+	if !req.Region {
+		if err := r.Run("grim", b.args(req, "")...); err != nil {
+			return fmt.Errorf("grim (fullscreen) failed: %w", err)
+		}
+		return nil
+	}
+
+	if !haveBinaries(r, "slurp") {
+		return fmt.Errorf("slurp is required for region screenshots for grim but not found in PATH")
+	}
+
+	// Optional: freeze screen with hyprpicker if available
+	if haveBinaries(r, "hyprpicker", "hyprctl") {
+		_ = r.Run("hyprctl", "keyword", "layerrule", "noanim,selection")
+		if hyprpicker, err := r.Start("hyprpicker", "-r", "-z"); err == nil {
+			defer hyprpicker.Process.Kill()
+			time.Sleep(200 * time.Millisecond)
+		}
+	}
+
+	slurpOutput, err := r.Output("slurp")
+	if err != nil {
+		return fmt.Errorf("failed to get region with slurp: %w", err)
+	}
+
+	geometry := strings.TrimSpace(string(slurpOutput))
+	if geometry == "" {
+		return fmt.Errorf("no region selected")
+	}
+	if area, err := ParseSlurpGeometry(geometry); err == nil {
+		rememberRegion(area)
+	}
+
+	if err := r.Run("grim", b.args(req, geometry)...); err != nil {
+		return fmt.Errorf("grim (region) failed: %w", err)
+	}
+	return nil
+}
+
+func (b grimBackend) CaptureArea(r Runner, req CaptureRequest, area Rect) error {
+	if err := r.Run("grim", b.args(req, area.GrimGeometry())...); err != nil {
+		return fmt.Errorf("grim (area) failed: %w", err)
+	}
+	return nil
+}
+
+func (grimBackend) outputArgs(req CaptureRequest, output string) []string {
+	if req.Cursor {
+		return []string{"-c", "-o", output, req.OutputPath}
+	}
+	return []string{"-o", output, req.OutputPath}
+}
+
+func (b grimBackend) CaptureOutput(r Runner, req CaptureRequest, output string) error {
+	if err := r.Run("grim", b.outputArgs(req, output)...); err != nil {
+		return fmt.Errorf("grim (output) failed: %w", err)
+	}
+	return nil
+}
+
+// --- xfce4-screenshooter
+
+type xfceScreenshooterBackend struct{}
+
+func (xfceScreenshooterBackend) Name() string       { return "xfce4-screenshooter" }
+func (xfceScreenshooterBackend) Sessions() []string { return []string{SessionX11} }
+
+func (xfceScreenshooterBackend) Available(r Runner) bool {
+	return haveBinaries(r, "xfce4-screenshooter")
+}
+
+func (xfceScreenshooterBackend) args(req CaptureRequest) []string {
+	args := []string{"-f"}
+	if req.Region {
+		args = []string{"-r"}
+	}
+	if req.Cursor {
+		args = append(args, "-m")
+	}
+	return append(args, "-s", req.OutputPath)
+}
+
+func (b xfceScreenshooterBackend) Capture(r Runner, req CaptureRequest) error {
+	if err := r.Run("xfce4-screenshooter", b.args(req)...); err != nil {
+		return fmt.Errorf("xfce4-screenshooter failed: %w", err)
+	}
+	return nil
+}
+
+// --- maim + slop
+
+type maimBackend struct{}
+
+func (maimBackend) Name() string       { return "maim" }
+func (maimBackend) Sessions() []string { return []string{SessionX11} }
+
+func (maimBackend) Available(r Runner) bool {
+	return haveBinaries(r, "maim")
+}
+
+// args builds the maim command line, geometry is in X11 "WxH+X+Y" form.
+// Unlike the other tools maim includes the cursor unless told not to.
+func (maimBackend) args(req CaptureRequest, geometry string) []string {
+	var args []string
+	if !req.Cursor {
+		args = append(args, "-u")
+	}
+	if geometry != "" {
+		args = append(args, "-g", geometry)
+	}
+	return append(args, req.OutputPath)
+}
+
+func (b maimBackend) Capture(r Runner, req CaptureRequest) error {
+	geometry := ""
+	if req.Region {
+		if !haveBinaries(r, "slop") {
+			return fmt.Errorf("maim requires 'slop' for region selection, but it was not found")
+		}
+		output, err := r.Output("slop", "-f", "%x,%y,%w,%h")
+		if err != nil {
+			return fmt.Errorf("slop failed: %w", err)
+		}
+		coords := strings.TrimSpace(string(output))
+		parts := strings.Split(coords, ",")
+		if len(parts) != 4 {
+			return fmt.Errorf("invalid region format from slop")
+		}
+		geometry = fmt.Sprintf("%sx%s+%s+%s", parts[2], parts[3], parts[0], parts[1])
+		if area, err := ParseX11Geometry(geometry); err == nil {
+			rememberRegion(area)
+		}
+	}
+
+	if err := r.Run("maim", b.args(req, geometry)...); err != nil {
+		return fmt.Errorf("maim failed: %w", err)
+	}
+	return nil
+}
+
+func (b maimBackend) CaptureArea(r Runner, req CaptureRequest, area Rect) error {
+	if err := r.Run("maim", b.args(req, area.X11Geometry())...); err != nil {
+		return fmt.Errorf("maim failed: %w", err)
+	}
+	return nil
+}
+
+// --- scrot
+
+type scrotBackend struct{}
+
+func (scrotBackend) Name() string       { return "scrot" }
+func (scrotBackend) Sessions() []string { return []string{SessionX11} }
+
+func (scrotBackend) Available(r Runner) bool {
+	return haveBinaries(r, "scrot")
+}
+
+func (scrotBackend) args(req CaptureRequest) []string {
+	var args []string
+	if req.Cursor {
+		args = append(args, "-p")
+	}
+	if req.Region {
+		args = append(args, "-s")
+	}
+	return append(args, req.OutputPath)
+}
+
+func (scrotBackend) areaArgs(req CaptureRequest, area Rect) []string {
+	var args []string
+	if req.Cursor {
+		args = append(args, "-p")
+	}
+	return append(args, "-a", fmt.Sprintf("%d,%d,%d,%d", area.X, area.Y, area.W, area.H), req.OutputPath)
+}
+
+func (b scrotBackend) CaptureArea(r Runner, req CaptureRequest, area Rect) error {
+	if err := r.Run("scrot", b.areaArgs(req, area)...); err != nil {
+		return fmt.Errorf("scrot failed: %w", err)
+	}
+	return nil
+}
+
+func (b scrotBackend) Capture(r Runner, req CaptureRequest) error {
+	if err := r.Run("scrot", b.args(req)...); err != nil {
+		return fmt.Errorf("scrot failed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestBackendArgs(t *testing.T) {
+	const out = "/tmp/shot.png"
+	area := Rect{X: 10, Y: 20, W: 300, H: 200}
+
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{"spectacle fullscreen", spectacleBackend{}.args(CaptureRequest{OutputPath: out}), []string{"-n", "-b", "-o", out}},
+		{"spectacle region", spectacleBackend{}.args(CaptureRequest{Region: true, OutputPath: out}), []string{"-n", "-b", "-o", out, "-r"}},
+		{"spectacle cursor", spectacleBackend{}.args(CaptureRequest{Cursor: true, OutputPath: out}), []string{"-n", "-b", "-o", out, "-p"}},
+
+		{"gnome-screenshot fullscreen", gnomeScreenshotBackend{}.args(CaptureRequest{OutputPath: out}), []string{"-f", out}},
+		{"gnome-screenshot cursor", gnomeScreenshotBackend{}.args(CaptureRequest{Cursor: true, OutputPath: out}), []string{"-p", "-f", out}},
+
+		{"flameshot fullscreen", flameshotBackend{}.args(CaptureRequest{OutputPath: out}), []string{"full", "--path", out}},
+		{"flameshot region", flameshotBackend{}.args(CaptureRequest{Region: true, OutputPath: out}), []string{"gui", "--path", out}},
+
+		{"grim fullscreen", grimBackend{}.args(CaptureRequest{OutputPath: out}, ""), []string{out}},
+		{"grim region", grimBackend{}.args(CaptureRequest{OutputPath: out}, area.GrimGeometry()), []string{"-g", "10,20 300x200", out}},
+		{"grim cursor", grimBackend{}.args(CaptureRequest{Cursor: true, OutputPath: out}, ""), []string{"-c", out}},
+		{"grim output", grimBackend{}.outputArgs(CaptureRequest{OutputPath: out}, "DP-1"), []string{"-o", "DP-1", out}},
+		{"grim output cursor", grimBackend{}.outputArgs(CaptureRequest{Cursor: true, OutputPath: out}, "DP-1"), []string{"-c", "-o", "DP-1", out}},
+
+		{"xfce4-screenshooter fullscreen", xfceScreenshooterBackend{}.args(CaptureRequest{OutputPath: out}), []string{"-f", "-s", out}},
+		{"xfce4-screenshooter region", xfceScreenshooterBackend{}.args(CaptureRequest{Region: true, OutputPath: out}), []string{"-r", "-s", out}},
+		{"xfce4-screenshooter cursor", xfceScreenshooterBackend{}.args(CaptureRequest{Cursor: true, OutputPath: out}), []string{"-f", "-m", "-s", out}},
+
+		{"maim fullscreen", maimBackend{}.args(CaptureRequest{OutputPath: out}, ""), []string{"-u", out}},
+		{"maim cursor", maimBackend{}.args(CaptureRequest{Cursor: true, OutputPath: out}, ""), []string{out}},
+		{"maim area", maimBackend{}.args(CaptureRequest{OutputPath: out}, area.X11Geometry()), []string{"-u", "-g", "300x200+10+20", out}},
+
+		{"scrot fullscreen", scrotBackend{}.args(CaptureRequest{OutputPath: out}), []string{out}},
+		{"scrot region", scrotBackend{}.args(CaptureRequest{Region: true, OutputPath: out}), []string{"-s", out}},
+		{"scrot cursor region", scrotBackend{}.args(CaptureRequest{Region: true, Cursor: true, OutputPath: out}), []string{"-p", "-s", out}},
+		{"scrot area", scrotBackend{}.areaArgs(CaptureRequest{OutputPath: out}, area), []string{"-a", "10,20,300,200", out}},
+	}
+
+	for _, tt := range tests {
+		if !slices.Equal(tt.args, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.name, tt.args, tt.want)
+		}
+	}
+}
+
+func TestBackendCaptureRunsBinary(t *testing.T) {
+	r := stubBinaries(t)
+	log := filepath.Join(t.TempDir(), "args.log")
+	stubScript(t, r, "scrot", `echo "$@" > `+log)
+
+	req := CaptureRequest{Region: true, OutputPath: "/tmp/shot.png"}
+	if err := (scrotBackend{}).Capture(r, req); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := os.ReadFile(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "-s /tmp/shot.png"; strings.TrimSpace(string(got)) != want {
+		t.Errorf("scrot called with %q, want %q", got, want)
+	}
+}
+
+func TestSelectBackendExplicit(t *testing.T) {
+	noSessionBus(t)
+	r := stubBinaries(t, "grim", "maim")
+
+	backend, err := SelectBackend(r, SessionWayland, "grim", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if backend.Name() != "grim" {
+		t.Errorf("got backend %q, want grim", backend.Name())
+	}
+
+	// An explicit choice wins over the detection order
+	backend, err = SelectBackend(r, SessionX11, "maim", []string{"scrot", "maim"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if backend.Name() != "maim" {
+		t.Errorf("got backend %q, want maim", backend.Name())
+	}
+}
+
+func TestSelectBackendErrors(t *testing.T) {
+	noSessionBus(t)
+	r := stubBinaries(t, "grim", "maim")
+
+	tests := []struct {
+		name     string
+		session  string
+		explicit string
+		want     string
+	}{
+		{"unknown", SessionWayland, "nosuchtool", "unknown screenshot backend"},
+		{"wrong session", SessionX11, "grim", "does not support x11 sessions"},
+		{"wrong session wayland", SessionWayland, "maim", "does not support wayland sessions"},
+		{"not installed", SessionWayland, "spectacle", "is not installed"},
+	}
+
+	for _, tt := range tests {
+		_, err := SelectBackend(r, tt.session, tt.explicit, nil)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got error %v, want it to contain %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestSelectBackendOrder(t *testing.T) {
+	noSessionBus(t)
+
+	tests := []struct {
+		name      string
+		installed []string
+		session   string
+		order     []string
+		want      string
+	}{
+		{"default wayland order", []string{"grim", "flameshot"}, SessionWayland, nil, "flameshot"},
+		{"default x11 order", []string{"scrot", "maim"}, SessionX11, nil, "maim"},
+		{"skips other sessions", []string{"grim", "scrot"}, SessionX11, nil, "scrot"},
+		{"configured order", []string{"maim", "scrot"}, SessionX11, []string{"scrot", "maim"}, "scrot"},
+		{"unknown names in order", []string{"maim"}, SessionX11, []string{"nosuchtool", "maim"}, "maim"},
+	}
+
+	for _, tt := range tests {
+		backend, err := SelectBackend(stubBinaries(t, tt.installed...), tt.session, "", tt.order)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if backend.Name() != tt.want {
+			t.Errorf("%s: got backend %q, want %q", tt.name, backend.Name(), tt.want)
+		}
+	}
+
+	_, err := SelectBackend(stubBinaries(t), SessionWayland, "", nil)
+	if err == nil || !strings.Contains(err.Error(), "no compatible screenshot tool") {
+		t.Errorf("got error %v with nothing installed, want no compatible screenshot tool", err)
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// colorFormats are the notations a picked color can be copied in
+var colorFormats = []string{"hex", "rgb", "hsl"}
+
+// PickColor lets the user click a pixel on screen and returns its color.
+// A fixed opts.Geometry picks the pixel at its top-left corner instead.
+// It returns false if picking was cancelled.
+func PickColor(r Runner, opts CaptureOptions) (color.RGBA, bool, error) {
+	if opts.Geometry != nil {
+		return captureColorAt(r, opts, image.Pt(opts.Geometry.X, opts.Geometry.Y))
+	}
+	if CurrentSession() == SessionX11 {
+		return pickX11Color(r, opts)
+	}
+	if haveBinaries(r, "hyprpicker") {
+		return pickHyprpickerColor(r)
+	}
+	return pickWaylandColor(r, opts)
+}
+
+// pickHyprpickerColor uses hyprpicker, which freezes the screen and shows a magnifier
+func pickHyprpickerColor(r Runner) (color.RGBA, bool, error) {
+	output, err := r.Output("hyprpicker", "-f", "hex")
+	if err != nil {
+		return color.RGBA{}, false, nil
+	}
+
+	// Older versions print log lines before the color
+	for _, line := range strings.Split(string(output), "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "#") {
+			continue
+		}
+		picked, err := parseHexColor(line, nil)
+		if err != nil {
+			return color.RGBA{}, false, fmt.Errorf("unexpected hyprpicker output: %w", err)
+		}
+		return color.RGBAModel.Convert(picked).(color.RGBA), true, nil
+	}
+
+	return color.RGBA{}, false, nil
+}
+
+// pickWaylandColor selects a point with slurp and captures that one pixel
+func pickWaylandColor(r Runner, opts CaptureOptions) (color.RGBA, bool, error) {
+	if !haveBinaries(r, "slurp") {
+		return color.RGBA{}, false, fmt.Errorf("picking colors on Wayland needs hyprpicker or slurp")
+	}
+
+	output, err := r.Output("slurp", "-p")
+	if err != nil {
+		return color.RGBA{}, false, nil
+	}
+	point, err := ParseSlurpGeometry(string(output))
+	if err != nil {
+		return color.RGBA{}, false, err
+	}
+
+	return captureColorAt(r, opts, image.Pt(point.X, point.Y))
+}
+
+// captureColorAt captures the single pixel at point. On Wayland grim is
+// used directly when installed, other tools capture the whole screen and
+// the pixel is cropped out of it.
+func captureColorAt(r Runner, opts CaptureOptions, point image.Point) (color.RGBA, bool, error) {
+	area := Rect{X: point.X, Y: point.Y, W: 1, H: 1}
+
+	var path string
+	if CurrentSession() == SessionWayland && haveBinaries(r, "grim") && (opts.Backend == "" || opts.Backend == "grim") {
+		tempDir, err := os.MkdirTemp("", "caplet-")
+		if err != nil {
+			return color.RGBA{}, false, fmt.Errorf("failed to create temp directory: %w", err)
+		}
+		path = filepath.Join(tempDir, "color.png")
+		if err := (grimBackend{}).CaptureArea(r, CaptureRequest{OutputPath: path}, area); err != nil {
+			os.RemoveAll(tempDir)
+			return color.RGBA{}, false, err
+		}
+	} else {
+		opts.Region = false
+		opts.Output = ""
+		opts.Geometry = &area
+		var err error
+		path, err = TakeScreenshot(opts)
+		if err != nil {
+			return color.RGBA{}, false, err
+		}
+	}
+	defer os.RemoveAll(filepath.Dir(path))
+
+	img, _, err := LoadImage(path)
+	if err != nil {
+		return color.RGBA{}, false, err
+	}
+
+	// Scaled outputs capture more than one pixel, they all share the color
+	return color.RGBAModel.Convert(img.At(img.Bounds().Min.X, img.Bounds().Min.Y)).(color.RGBA), true, nil
+}
+
+// pickX11Color captures the screen first and lets the user click a point
+// over the frozen image with slop, then reads the pixel from the capture
+func pickX11Color(r Runner, opts CaptureOptions) (color.RGBA, bool, error) {
+	if !haveBinaries(r, "slop") {
+		return color.RGBA{}, false, fmt.Errorf("picking colors on X11 needs slop")
+	}
+
+	opts.Region = false
+	opts.Output = ""
+	opts.Geometry = nil
+	path, err := TakeScreenshot(opts)
+	if err != nil {
+		return color.RGBA{}, false, err
+	}
+	defer os.RemoveAll(filepath.Dir(path))
+	if !FileExists(path) {
+		return color.RGBA{}, false, nil
+	}
+
+	frozen, err := freezeScreen(r, SessionX11)
+	if err == nil {
+		closeViewers, err := frozen.show(r, path)
+		if err != nil {
+			return color.RGBA{}, false, err
+		}
+		defer closeViewers()
+	} else {
+		fmt.Fprintf(os.Stderr, "Not freezing the screen: %v\n", err)
+	}
+
+	// A tolerance of 0 turns a click into a point instead of a window selection
+	output, err := r.Output("slop", "-t", "0", "-f", "%x %y")
+	if err != nil {
+		return color.RGBA{}, false, nil
+	}
+	var point image.Point
+	if _, err := fmt.Sscanf(strings.TrimSpace(string(output)), "%d %d", &point.X, &point.Y); err != nil {
+		return color.RGBA{}, false, fmt.Errorf("unexpected slop output %q", output)
+	}
+
+	img, _, err := LoadImage(path)
+	if err != nil {
+		return color.RGBA{}, false, err
+	}
+	if !point.In(img.Bounds()) {
+		return color.RGBA{}, false, fmt.Errorf("picked point %d,%d lies outside the captured screen", point.X, point.Y)
+	}
+
+	return color.RGBAModel.Convert(img.At(point.X, point.Y)).(color.RGBA), true, nil
+}
+
+// FormatColor writes c in the given notation: hex, rgb or hsl
+func FormatColor(c color.RGBA, format string) (string, error) {
+	switch strings.ToLower(format) {
+	case "hex", "":
+		return fmt.Sprintf("#%02X%02X%02X", c.R, c.G, c.B), nil
+	case "rgb":
+		return fmt.Sprintf("rgb(%d, %d, %d)", c.R, c.G, c.B), nil
+	case "hsl":
+		h, s, l := rgbToHSL(c)
+		return fmt.Sprintf("hsl(%d, %d%%, %d%%)", int(math.Round(h))%360, int(math.Round(s*100)), int(math.Round(l*100))), nil
+	}
+	return "", fmt.Errorf("unknown color format %q (%s)", format, strings.Join(colorFormats, ", "))
+}
+
+// rgbToHSL converts c to hue in degrees and saturation and lightness in 0..1
+func rgbToHSL(c color.RGBA) (float64, float64, float64) {
+	r, g, b := float64(c.R)/255, float64(c.G)/255, float64(c.B)/255
+	maxC, minC := max(r, g, b), min(r, g, b)
+	l := (maxC + minC) / 2
+	if maxC == minC {
+		return 0, 0, l
+	}
+
+	d := maxC - minC
+	s := d / (1 - math.Abs(2*l-1))
+
+	var h float64
+	switch maxC {
+	case r:
+		h = math.Mod((g-b)/d, 6)
+	case g:
+		h = (b-r)/d + 2
+	default:
+		h = (r-g)/d + 4
+	}
+	h *= 60
+	if h < 0 {
+		h += 360
+	}
+
+	return h, s, l
+}
+
+// WriteColorSwatch writes a small PNG filled with c, used as notification icon
+func WriteColorSwatch(c color.RGBA) (string, error) {
+	tempDir, err := os.MkdirTemp("", "caplet-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp directory: %w", err)
+	}
+
+	path := filepath.Join(tempDir, fmt.Sprintf("color-%s.png", time.Now().Format("2006-01-02_15-04-05")))
+	swatch := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	draw.Draw(swatch, swatch.Bounds(), image.NewUniform(c), image.Point{}, draw.Src)
+
+	file, err := os.Create(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to create color swatch: %w", err)
+	}
+	defer file.Close()
+
+	if err := png.Encode(file, swatch); err != nil {
+		return "", fmt.Errorf("failed to encode color swatch: %w", err)
+	}
+
+	return path, nil
+}
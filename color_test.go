@@ -0,0 +1,93 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFormatColor(t *testing.T) {
+	tests := []struct {
+		name  string
+		color color.RGBA
+		hex   string
+		rgb   string
+		hsl   string
+	}{
+		{"red", color.RGBA{R: 255, A: 255}, "#FF0000", "rgb(255, 0, 0)", "hsl(0, 100%, 50%)"},
+		{"green", color.RGBA{G: 255, A: 255}, "#00FF00", "rgb(0, 255, 0)", "hsl(120, 100%, 50%)"},
+		{"blue", color.RGBA{B: 255, A: 255}, "#0000FF", "rgb(0, 0, 255)", "hsl(240, 100%, 50%)"},
+		{"yellow", color.RGBA{R: 255, G: 255, A: 255}, "#FFFF00", "rgb(255, 255, 0)", "hsl(60, 100%, 50%)"},
+		{"cyan", color.RGBA{G: 255, B: 255, A: 255}, "#00FFFF", "rgb(0, 255, 255)", "hsl(180, 100%, 50%)"},
+		{"magenta", color.RGBA{R: 255, B: 255, A: 255}, "#FF00FF", "rgb(255, 0, 255)", "hsl(300, 100%, 50%)"},
+		{"dark red", color.RGBA{R: 128, A: 255}, "#800000", "rgb(128, 0, 0)", "hsl(0, 100%, 25%)"},
+		{"black", color.RGBA{A: 255}, "#000000", "rgb(0, 0, 0)", "hsl(0, 0%, 0%)"},
+		{"white", color.RGBA{R: 255, G: 255, B: 255, A: 255}, "#FFFFFF", "rgb(255, 255, 255)", "hsl(0, 0%, 100%)"},
+		{"grey", color.RGBA{R: 128, G: 128, B: 128, A: 255}, "#808080", "rgb(128, 128, 128)", "hsl(0, 0%, 50%)"},
+		{"light grey", color.RGBA{R: 192, G: 192, B: 192, A: 255}, "#C0C0C0", "rgb(192, 192, 192)", "hsl(0, 0%, 75%)"},
+	}
+
+	for _, tt := range tests {
+		for format, want := range map[string]string{"hex": tt.hex, "rgb": tt.rgb, "hsl": tt.hsl} {
+			got, err := FormatColor(tt.color, format)
+			if err != nil {
+				t.Errorf("%s as %s: %v", tt.name, format, err)
+				continue
+			}
+			if got != want {
+				t.Errorf("%s as %s: got %q, want %q", tt.name, format, got, want)
+			}
+		}
+	}
+
+	if _, err := FormatColor(color.RGBA{}, "cmyk"); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
+
+func TestCaptureColorAtUsesGrim(t *testing.T) {
+	t.Setenv("WAYLAND_DISPLAY", "wayland-test")
+	dir := t.TempDir()
+
+	// A 2x2 capture, like grim produces for one pixel on a scale 2 output
+	pixel := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	for y := range 2 {
+		for x := range 2 {
+			pixel.SetRGBA(x, y, color.RGBA{R: 0x12, G: 0x34, B: 0x56, A: 255})
+		}
+	}
+	pixelPath := filepath.Join(dir, "pixel.png")
+	file, err := os.Create(pixelPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(file, pixel); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	// The fake grim logs its arguments and writes the pixel to the last one
+	log := filepath.Join(dir, "args.log")
+	r := stubBinaries(t)
+	stubScript(t, r, "grim", `echo "$@" > `+log+"\nfor last; do :; done\ncp "+pixelPath+` "$last"`)
+
+	picked, ok, err := captureColorAt(r, CaptureOptions{}, image.Pt(300, 200))
+	if err != nil || !ok {
+		t.Fatalf("got ok=%v, err=%v", ok, err)
+	}
+	if want := (color.RGBA{R: 0x12, G: 0x34, B: 0x56, A: 255}); picked != want {
+		t.Errorf("got color %v, want %v", picked, want)
+	}
+
+	args, err := os.ReadFile(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(args), "-g 300,200 1x1 ") {
+		t.Errorf("grim called with %q, want -g 300,200 1x1", args)
+	}
+}
@@ -19,6 +19,56 @@ type SiteConfig struct {
 	Regexps      map[string]string `json:"regexps"`
 	Headers      map[string]string `json:"headers,omitempty"`
 	Arguments    map[string]string `json:"arguments,omitempty"`
+	DeletionURL  string            `json:"deletionURL,omitempty"`
+	// Limits of the host, images are recompressed or converted to fit
+	MaxFileSizeMB float64  `json:"maxFileSizeMB,omitempty"`
+	AcceptedTypes []string `json:"acceptedTypes,omitempty"`
+	// StripMetadata overrides the global setting for this uploader
+	StripMetadata *bool `json:"stripMetadata,omitempty"`
+}
+
+// RetentionConfig limits how much upload history and how many saved files are kept
+type RetentionConfig struct {
+	MaxAgeDays   int   `json:"maxAgeDays,omitempty"`
+	MaxCount     int   `json:"maxCount,omitempty"`
+	MaxSizeMB    int64 `json:"maxSizeMB,omitempty"`
+	PruneHistory bool  `json:"pruneHistory,omitempty"`
+	DeleteRemote bool  `json:"deleteRemote,omitempty"`
+}
+
+// RecordingConfig controls screen recordings made with -mode record
+type RecordingConfig struct {
+	Format   string `json:"format,omitempty"`
+	Audio    bool   `json:"audio,omitempty"`
+	FPS      int    `json:"fps,omitempty"`
+	GifFPS   int    `json:"gifFps,omitempty"`
+	GifWidth int    `json:"gifWidth,omitempty"`
+}
+
+// ScrollConfig controls scrolling captures made with -mode scroll
+type ScrollConfig struct {
+	Auto      bool `json:"auto,omitempty"`
+	Interval  int  `json:"interval,omitempty"`
+	Amount    int  `json:"amount,omitempty"`
+	MaxFrames int  `json:"maxFrames,omitempty"`
+}
+
+// RedactionConfig sets the defaults for -redact and -redact-select
+type RedactionConfig struct {
+	Style        string `json:"style,omitempty"`
+	KeepOriginal bool   `json:"keepOriginal,omitempty"`
+}
+
+// OCRConfig sets the defaults for -mode ocr
+type OCRConfig struct {
+	Language   string `json:"language,omitempty"`
+	UploadText bool   `json:"uploadText,omitempty"`
+}
+
+// QRConfig sets the defaults for -qr and -qr-copy
+type QRConfig struct {
+	Enabled   bool `json:"enabled,omitempty"`
+	CopyImage bool `json:"copyImage,omitempty"`
 }
 
 // Config represents the application configuration
@@ -26,9 +76,26 @@ type Config struct {
 	DefaultFileUpload   string                `json:"defaultFileUpload"`
 	DefaultImageUpload  string                `json:"defaultImageUpload"`
 	DefaultURLShortener string                `json:"defaultUrlShortener,omitempty"`
+	DefaultTextUpload   string                `json:"defaultTextUpload,omitempty"`
 	HistoryPath         string                `json:"historyPath"`
 	SaveDir             string                `json:"saveDir"`
 	Organized           bool                  `json:"organized"`
+	Retention           RetentionConfig       `json:"retention"`
+	ScreenshotBackend   string                `json:"screenshotBackend,omitempty"`
+	BackendOrder        []string              `json:"backendOrder,omitempty"`
+	Cursor              bool                  `json:"cursor,omitempty"`
+	Freeze              bool                  `json:"freeze,omitempty"`
+	Regions             map[string]string     `json:"regions,omitempty"`
+	Recording           RecordingConfig       `json:"recording"`
+	Scroll              ScrollConfig          `json:"scroll"`
+	Pipeline            []PipelineTask        `json:"pipeline,omitempty"`
+	Redaction           RedactionConfig       `json:"redaction"`
+	Annotate            bool                  `json:"annotate,omitempty"`
+	OCR                 OCRConfig             `json:"ocr"`
+	QR                  QRConfig              `json:"qr"`
+	ScanAction          string                `json:"scanAction,omitempty"`
+	ColorFormat         string                `json:"colorFormat,omitempty"`
+	StripMetadata       bool                  `json:"stripMetadata,omitempty"`
 	Uploaders           map[string]SiteConfig `json:"uploaders"`
 	Shorteners          map[string]SiteConfig `json:"shorteners"`
 }
@@ -41,7 +108,10 @@ func DefaultConfig() Config {
 		HistoryPath:        "$HOME/Pictures/Screenshots/caplet",
 		SaveDir:            "$HOME/Pictures/Screenshots/caplet",
 		Organized:          true,
-		Shorteners:         map[string]SiteConfig{},
+		Recording: RecordingConfig{
+			Format: "mp4",
+		},
+		Shorteners: map[string]SiteConfig{},
 		Uploaders: map[string]SiteConfig{
 			"imgur": {
 				Name:         "Imgur",
@@ -60,6 +130,21 @@ func DefaultConfig() Config {
 	}
 }
 
+// FindUploader looks up an uploader by config key or by service name
+func FindUploader(config Config, name string) (SiteConfig, bool) {
+	if service, found := config.Uploaders[name]; found {
+		return service, true
+	}
+
+	for _, service := range config.Uploaders {
+		if strings.EqualFold(service.Name, name) {
+			return service, true
+		}
+	}
+
+	return SiteConfig{}, false
+}
+
 // ExtractJSONKeys extracts JSON keys from fields
 func ExtractJSONKeys(fields ...string) map[string]string {
 	regexps := make(map[string]string)
@@ -81,6 +166,88 @@ func ExtractJSONKeys(fields ...string) map[string]string {
 	return regexps
 }
 
+// ResolveStripMetadata reports whether uploads to service should have their
+// metadata removed, preferring the uploader's own setting
+func ResolveStripMetadata(config Config, service SiteConfig) bool {
+	if service.StripMetadata != nil {
+		return *service.StripMetadata
+	}
+	return config.StripMetadata
+}
+
+// ResolveResponseTemplate fills $json:key$ placeholders in a template
+// with the matching values from an upload response. The first occurrence
+// of key anywhere in the JSON document is used.
+func ResolveResponseTemplate(template string, response string) string {
+	if template == "" {
+		return ""
+	}
+
+	re := regexp.MustCompile(`\$json:([a-zA-Z0-9_]+)\$`)
+	return re.ReplaceAllStringFunc(template, func(match string) string {
+		key := re.FindStringSubmatch(match)[1]
+		value, _ := findJSONValue(json.NewDecoder(strings.NewReader(response)), key)
+		return value
+	})
+}
+
+// findJSONValue reads the next value from dec and searches it for key in
+// document order. Strings are returned unquoted, other scalars as written
+// and objects or arrays as compact JSON.
+func findJSONValue(dec *json.Decoder, key string) (string, bool) {
+	token, err := dec.Token()
+	if err != nil {
+		return "", false
+	}
+
+	switch token {
+	case json.Delim('{'):
+		for dec.More() {
+			name, err := dec.Token()
+			if err != nil {
+				return "", false
+			}
+			if name == key {
+				var raw json.RawMessage
+				if err := dec.Decode(&raw); err != nil {
+					return "", false
+				}
+				return formatJSONValue(raw), true
+			}
+			if value, found := findJSONValue(dec, key); found {
+				return value, true
+			}
+		}
+		dec.Token()
+
+	case json.Delim('['):
+		for dec.More() {
+			if value, found := findJSONValue(dec, key); found {
+				return value, true
+			}
+		}
+		dec.Token()
+	}
+
+	return "", false
+}
+
+// formatJSONValue turns a raw JSON value into template text
+func formatJSONValue(raw json.RawMessage) string {
+	var value interface{}
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return ""
+	}
+
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	}
+	return strings.TrimSpace(string(raw))
+}
+
 // ImportSXCU imports ShareX custom uploader configs
 func ImportSXCU(path string) error {
 	// Read the SXCU file
@@ -116,6 +283,11 @@ func ImportSXCU(path string) error {
 		Regexps:      regexps,
 	}
 
+	// Keep the deletion URL template so it can be resolved after uploading
+	if deletionURL, ok := sxcu["DeletionURL"].(string); ok {
+		siteConfig.DeletionURL = deletionURL
+	}
+
 	// Set RequestType if it exists, otherwise default to POST
 	if requestMethod, ok := sxcu["RequestMethod"].(string); ok {
 		siteConfig.RequestType = requestMethod
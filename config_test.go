@@ -0,0 +1,34 @@
+package main
+
+import "testing"
+
+func TestResolveResponseTemplate(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		response string
+		want     string
+	}{
+		{"string", "https://host/delete/$json:deletehash$", `{"link":"https://host/a.png","deletehash":"abc123"}`, "https://host/delete/abc123"},
+		{"comma in value", "$json:title$", `{"title":"one, two","id":1}`, "one, two"},
+		{"escaped quote", "$json:title$", `{"title":"say \"hi\", ok"}`, `say "hi", ok`},
+		{"escaped slashes", "$json:url$", `{"url":"https:\/\/host\/d\/x"}`, "https://host/d/x"},
+		{"number", "https://host/del?id=$json:id$", `{"id": 4521, "name": "x"}`, "https://host/del?id=4521"},
+		{"boolean", "$json:ok$", `{"ok":true}`, "true"},
+		{"null", "x$json:token$", `{"token":null}`, "x"},
+		{"nested", "$json:deletehash$", `{"data":{"link":"l","deletehash":"nested"},"success":true}`, "nested"},
+		{"in array", "$json:key$", `{"files":[{"name":"a"},{"key":"k1"},{"key":"k2"}]}`, "k1"},
+		{"first in document order", "$json:id$", `{"meta":{"id":"inner"},"id":"outer"}`, "inner"},
+		{"key only as value", "$json:id$", `{"name":"id","other":1}`, ""},
+		{"missing key", "https://host/$json:nothere$", `{"link":"l"}`, "https://host/"},
+		{"several placeholders", "$json:a$/$json:b$", `{"a":"1","b":2}`, "1/2"},
+		{"not json", "$json:a$", `<html>a</html>`, ""},
+		{"empty template", "", `{"a":"1"}`, ""},
+	}
+
+	for _, tt := range tests {
+		if got := ResolveResponseTemplate(tt.template, tt.response); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
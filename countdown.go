@@ -0,0 +1,77 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// Countdown waits the given number of seconds before a capture, showing
+// the remaining time in a notification and ticking every second.
+// It returns false if the countdown was cancelled with Ctrl+C or "caplet cancel".
+func Countdown(seconds int, showNotification bool) (bool, error) {
+	removePidFile, err := WritePidFile("countdown")
+	if err != nil {
+		return false, err
+	}
+	defer removePidFile()
+
+	cancel := make(chan os.Signal, 1)
+	signal.Notify(cancel, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(cancel)
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for remaining := seconds; remaining > 0; remaining-- {
+		fmt.Printf("Capturing in %d...\n", remaining)
+		if showNotification {
+			NOTIFY_ID, err = Notify(fmt.Sprintf("Capturing in %d... (run 'caplet cancel' to abort)", remaining), NOTIFY_ID, "")
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to show notification: %v\n", err)
+			}
+		}
+		go PlayTick()
+
+		select {
+		case <-cancel:
+			fmt.Println("Capture cancelled.")
+			if showNotification {
+				NOTIFY_ID, _ = Notify("Capture cancelled", NOTIFY_ID, "")
+			}
+			return false, nil
+		case <-ticker.C:
+		}
+	}
+
+	// Keep the countdown notification out of the screenshot
+	if showNotification && NOTIFY_ID != "" {
+		if err := CloseNotification(NOTIFY_ID); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to close notification: %v\n", err)
+		}
+		NOTIFY_ID = ""
+		time.Sleep(300 * time.Millisecond)
+	}
+
+	return true, nil
+}
+
+// RunCancelCommand handles "caplet cancel", aborting a running countdown
+func RunCancelCommand(config Config, args []string) error {
+	fs := flag.NewFlagSet("cancel", flag.ExitOnError)
+	fs.Parse(args)
+
+	cancelled, err := SignalInstance("countdown", syscall.SIGTERM)
+	if err != nil {
+		return err
+	}
+	if !cancelled {
+		return fmt.Errorf("no capture countdown is running")
+	}
+
+	fmt.Println("Countdown cancelled.")
+	return nil
+}
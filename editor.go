@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// maxEditedImageSize limits the size of an annotated image posted back
+const maxEditedImageSize = 256 << 20
+
+// EditImage opens the capture in a local browser-based annotation editor
+// and waits until the user is done. The edited image replaces the file at
+// path. It returns false if the user cancelled the upload.
+func EditImage(path string, showNotification bool) (bool, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return false, fmt.Errorf("failed to listen: %w", err)
+	}
+	defer listener.Close()
+	host := listener.Addr().String()
+
+	// The editor lives under a random path so other local pages can't
+	// read the capture or post to it
+	tokenBytes := make([]byte, 16)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		return false, fmt.Errorf("failed to create editor token: %w", err)
+	}
+	prefix := "/" + hex.EncodeToString(tokenBytes)
+
+	done := make(chan bool, 1)
+	finish := func(proceed bool) {
+		select {
+		case done <- proceed:
+		default:
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+prefix+"/{$}", func(w http.ResponseWriter, r *http.Request) {
+		page, err := webFiles.ReadFile("web/editor.html")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(page)
+	})
+	mux.HandleFunc("GET "+prefix+"/image", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		http.ServeFile(w, r, path)
+	})
+	mux.HandleFunc("POST "+prefix+"/save", func(w http.ResponseWriter, r *http.Request) {
+		data, err := io.ReadAll(io.LimitReader(r.Body, maxEditedImageSize))
+		if err != nil {
+			writeJSONError(w, err, http.StatusBadRequest)
+			return
+		}
+		if _, format, err := image.DecodeConfig(bytes.NewReader(data)); err != nil || format != "png" {
+			writeJSONError(w, fmt.Errorf("expected a PNG image"), http.StatusBadRequest)
+			return
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			writeJSONError(w, err, http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, map[string]bool{"ok": true})
+		finish(true)
+	})
+	mux.HandleFunc("POST "+prefix+"/cancel", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]bool{"ok": true})
+		finish(false)
+	})
+
+	server := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !isLocalHost(r, host) {
+				http.Error(w, "forbidden", http.StatusForbidden)
+				return
+			}
+			mux.ServeHTTP(w, r)
+		}),
+	}
+	go server.Serve(listener)
+	defer server.Close()
+
+	url := "http://" + host + prefix + "/"
+	fmt.Printf("Annotate the capture at %s\n", url)
+	if showNotification {
+		NOTIFY_ID, err = Notify("Annotate the capture in your browser, then press Done", NOTIFY_ID, "")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to show notification: %v\n", err)
+		}
+	}
+	if err := OpenURL(defaultRunner, url); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to open browser: %v\n", err)
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(interrupt)
+
+	select {
+	case proceed := <-done:
+		return proceed, nil
+	case <-interrupt:
+		return false, nil
+	}
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"fmt"
+	"image/png"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// freezeViewers are the image viewers that can show the frozen screen
+// fullscreen, in order of preference per session
+var freezeViewers = map[string][][]string{
+	SessionWayland: {{"imv", "-f"}, {"swayimg", "-f"}},
+	SessionX11:     {{"feh", "-F", "-Z"}, {"imv", "-f"}},
+}
+
+// freezeViewer returns the command line of the first installed viewer
+func freezeViewer(r Runner, session string) ([]string, bool) {
+	for _, viewer := range freezeViewers[session] {
+		if haveBinaries(r, viewer[0]) {
+			return viewer, true
+		}
+	}
+	return nil, false
+}
+
+// frozenScreen is what freezeScreen found: the monitors to cover with a
+// still image each and the viewer that shows them
+type frozenScreen struct {
+	session  string
+	monitors []Monitor
+	viewer   []string
+}
+
+// freezeScreen checks that the screen can be frozen, or returns an error
+// explaining why not so the caller can fall back to a live selection
+func freezeScreen(r Runner, session string) (frozenScreen, error) {
+	selector := "slurp"
+	if session == SessionX11 {
+		selector = "slop"
+	}
+	if !haveBinaries(r, selector) {
+		return frozenScreen{}, fmt.Errorf("freezing the screen needs %s", selector)
+	}
+
+	viewer, found := freezeViewer(r, session)
+	if !found {
+		var names []string
+		for _, viewer := range freezeViewers[session] {
+			names = append(names, viewer[0])
+		}
+		return frozenScreen{}, fmt.Errorf("freezing the screen needs %s", strings.Join(names, " or "))
+	}
+
+	// Each monitor gets its own viewer, and the layout maps the selection
+	// onto scaled captures
+	monitors, err := ListMonitors(r)
+	if err != nil {
+		return frozenScreen{}, fmt.Errorf("freezing the screen needs the monitor layout: %w", err)
+	}
+	if len(monitors) == 0 {
+		return frozenScreen{}, fmt.Errorf("freezing the screen needs the monitor layout, but no monitors were found")
+	}
+
+	frozen := frozenScreen{session: session, monitors: monitors, viewer: viewer}
+	if len(monitors) > 1 {
+		if _, _, err := frozen.placeViewer(r, monitors[0]); err != nil {
+			return frozenScreen{}, err
+		}
+	}
+
+	return frozen, nil
+}
+
+// placeViewer returns the viewer command that covers monitor, and the
+// command to run before it, if any. Viewers open fullscreen on the focused
+// output on Wayland, so the compositor is asked to focus monitor first;
+// feh on X11 is given the monitor's geometry instead.
+func (f frozenScreen) placeViewer(r Runner, monitor Monitor) ([]string, []string, error) {
+	if f.session == SessionX11 {
+		if f.viewer[0] != "feh" {
+			return nil, nil, fmt.Errorf("freezing several monitors on X11 needs feh")
+		}
+		return []string{"feh", "-x", "-Z", "-g", monitor.Geometry.X11Geometry()}, nil, nil
+	}
+
+	switch {
+	case os.Getenv("HYPRLAND_INSTANCE_SIGNATURE") != "" && haveBinaries(r, "hyprctl"):
+		return f.viewer, []string{"hyprctl", "dispatch", "focusmonitor", monitor.Name}, nil
+	case os.Getenv("SWAYSOCK") != "" && haveBinaries(r, "swaymsg"):
+		return f.viewer, []string{"swaymsg", "focus", "output", monitor.Name}, nil
+	}
+	return nil, nil, fmt.Errorf("freezing several monitors on Wayland needs Hyprland or sway")
+}
+
+// show covers every monitor with its part of the fullscreen capture at path.
+// The returned function closes the viewers again.
+func (f frozenScreen) show(r Runner, path string) (func(), error) {
+	if len(f.monitors) == 1 {
+		return showFrozenScreen(r, f.viewer, path)
+	}
+
+	img, _, err := LoadImage(path)
+	if err != nil {
+		return nil, err
+	}
+	layout := layoutArea(f.monitors)
+	origin, scale := captureOrigin(path, &layout)
+
+	stillDir, err := os.MkdirTemp("", "caplet-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	var closers []func()
+	closeAll := func() {
+		for _, closeViewer := range closers {
+			closeViewer()
+		}
+		os.RemoveAll(stillDir)
+	}
+
+	// The focused monitor goes last, so it keeps the focus afterwards
+	monitors := slices.Clone(f.monitors)
+	slices.SortStableFunc(monitors, func(a, b Monitor) int {
+		switch {
+		case a.Focused == b.Focused:
+			return 0
+		case a.Focused:
+			return 1
+		}
+		return -1
+	})
+
+	for i, monitor := range monitors {
+		still, err := cropImage(img, scaleRect(monitor.Geometry, origin, scale))
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		// Only shown for a moment, so favour speed over size
+		stillPath := filepath.Join(stillDir, fmt.Sprintf("frozen-%d.png", i))
+		file, err := os.Create(stillPath)
+		if err != nil {
+			closeAll()
+			return nil, fmt.Errorf("failed to create frozen screen: %w", err)
+		}
+		encoder := png.Encoder{CompressionLevel: png.BestSpeed}
+		err = encoder.Encode(file, still)
+		file.Close()
+		if err != nil {
+			closeAll()
+			return nil, fmt.Errorf("failed to encode frozen screen: %w", err)
+		}
+
+		viewer, focus, err := f.placeViewer(r, monitor)
+		if err == nil && focus != nil {
+			if err = r.Run(focus[0], focus[1:]...); err != nil {
+				err = fmt.Errorf("failed to focus monitor %s: %w", monitor.Name, err)
+			}
+		}
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		closeViewer, err := showFrozenScreen(r, viewer, stillPath)
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		closers = append(closers, closeViewer)
+	}
+
+	return closeAll, nil
+}
+
+// showFrozenScreen shows the capture at path fullscreen with viewer.
+// The returned function closes the viewer again.
+func showFrozenScreen(r Runner, viewer []string, path string) (func(), error) {
+	cmd, err := r.Start(viewer[0], slices.Concat(viewer[1:], []string{path})...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to show frozen screen with %s: %w", viewer[0], err)
+	}
+
+	// Give the viewer time to cover the screen before selecting
+	time.Sleep(300 * time.Millisecond)
+	return func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	}, nil
+}
+
+// captureFrozenRegion captures the whole screen, shows it fullscreen in an
+// image viewer while the user selects a region over the still image, and
+// crops the selection out. A cancelled selection leaves no file behind.
+func captureFrozenRegion(r Runner, backend ScreenshotBackend, req CaptureRequest, frozen frozenScreen) error {
+	fullReq := req
+	fullReq.Region = false
+	if err := backend.Capture(r, fullReq); err != nil {
+		return err
+	}
+	if !FileExists(req.OutputPath) {
+		return nil
+	}
+
+	closeViewers, err := frozen.show(r, req.OutputPath)
+	if err != nil {
+		os.Remove(req.OutputPath)
+		return err
+	}
+	area, selected, err := SelectArea(r)
+	closeViewers()
+	if err != nil || !selected {
+		os.Remove(req.OutputPath)
+		return err
+	}
+	rememberRegion(area)
+
+	// Selections are in logical coordinates, HiDPI captures have more pixels
+	layout := layoutArea(frozen.monitors)
+	origin, scale := captureOrigin(req.OutputPath, &layout)
+	crop := scaleRect(area, origin, scale)
+
+	return CropImageFile(req.OutputPath, crop)
+}
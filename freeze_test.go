@@ -0,0 +1,170 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestFreezeScreen(t *testing.T) {
+	t.Setenv("WAYLAND_DISPLAY", "wayland-test")
+	t.Setenv("HYPRLAND_INSTANCE_SIGNATURE", "")
+
+	twoMonitors := `[
+  {"name": "DP-1", "enabled": true, "position": {"x": 0, "y": 0}, "scale": 1, "modes": [{"width": 1920, "height": 1080, "current": true}]},
+  {"name": "DP-2", "enabled": true, "position": {"x": 1920, "y": 0}, "scale": 1, "modes": [{"width": 1920, "height": 1080, "current": true}]}
+]`
+	tests := []struct {
+		name    string
+		randr   string // wlr-randr output, empty if not installed
+		sway    string // swaymsg get_outputs output, empty if not running sway
+		want    int
+		wantErr string
+	}{
+		{"unknown layout", "", "", 0, "needs the monitor layout"},
+		{"one monitor", `[
+  {"name": "eDP-1", "enabled": true, "position": {"x": 0, "y": 0}, "scale": 2, "modes": [{"width": 2880, "height": 1800, "current": true}]}
+]`, "", 1, ""},
+		{"two monitors without a compositor to focus them", twoMonitors, "", 0, "needs Hyprland or sway"},
+		{"two monitors on sway", "", `[
+  {"name": "DP-1", "active": true, "focused": true, "rect": {"x": 0, "y": 0, "width": 1920, "height": 1080}},
+  {"name": "DP-2", "active": true, "focused": false, "rect": {"x": 1920, "y": 0, "width": 1920, "height": 1080}}
+]`, 2, ""},
+	}
+
+	for _, tt := range tests {
+		r := stubBinaries(t, "slurp", "imv")
+		if tt.randr != "" {
+			stubPrint(t, r, "wlr-randr", tt.randr)
+		}
+		t.Setenv("SWAYSOCK", "")
+		if tt.sway != "" {
+			t.Setenv("SWAYSOCK", "/run/sway-test.sock")
+			stubPrint(t, r, "swaymsg", tt.sway)
+		}
+
+		frozen, err := freezeScreen(r, SessionWayland)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: got error %v, want it to contain %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if len(frozen.monitors) != tt.want || len(frozen.viewer) == 0 || frozen.viewer[0] != "imv" {
+			t.Errorf("%s: got monitors %+v and viewer %q, want %d monitors and imv", tt.name, frozen.monitors, frozen.viewer, tt.want)
+		}
+	}
+}
+
+func TestFreezeScreenX11(t *testing.T) {
+	t.Setenv("WAYLAND_DISPLAY", "")
+	xrandr := `Monitors: 2
+ 0: +*DP-1 1920/530x1080/300+0+0  DP-1
+ 1: +DP-2 1920/530x1080/300+1920+0  DP-2`
+
+	r := stubBinaries(t, "slop", "imv")
+	stubPrint(t, r, "xrandr", xrandr)
+	if _, err := freezeScreen(r, SessionX11); err == nil || !strings.Contains(err.Error(), "needs feh") {
+		t.Errorf("got error %v, want it to ask for feh", err)
+	}
+
+	stubScript(t, r, "feh", "exit 0")
+	frozen, err := freezeScreen(r, SessionX11)
+	if err != nil {
+		t.Fatal(err)
+	}
+	viewer, focus, err := frozen.placeViewer(r, frozen.monitors[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"feh", "-x", "-Z", "-g", "1920x1080+1920+0"}; !slices.Equal(viewer, want) || focus != nil {
+		t.Errorf("got viewer %q and focus %q, want %q and no focus", viewer, focus, want)
+	}
+}
+
+func TestFrozenScreenShow(t *testing.T) {
+	t.Setenv("WAYLAND_DISPLAY", "wayland-test")
+	t.Setenv("HYPRLAND_INSTANCE_SIGNATURE", "test")
+
+	// The viewer keeps a copy of each still, hyprctl logs the focus order
+	logDir := t.TempDir()
+	r := stubBinaries(t)
+	stubScript(t, r, "imv", `for last; do :; done
+cp "$last" `+logDir+`/
+exec sleep 10`)
+	stubScript(t, r, "hyprctl", `echo "$@" >> `+logDir+`/focus`)
+
+	// Two monitors side by side, captured at twice their logical size
+	red := color.RGBA{255, 0, 0, 255}
+	blue := color.RGBA{0, 0, 255, 255}
+	capture := image.NewRGBA(image.Rect(0, 0, 140, 48))
+	draw.Draw(capture, image.Rect(0, 0, 80, 40), &image.Uniform{red}, image.Point{}, draw.Src)
+	draw.Draw(capture, image.Rect(80, 8, 140, 40), &image.Uniform{blue}, image.Point{}, draw.Src)
+	path := filepath.Join(t.TempDir(), "capture.png")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(file, capture); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	frozen := frozenScreen{
+		session: SessionWayland,
+		monitors: []Monitor{
+			{Name: "DP-1", Geometry: Rect{W: 40, H: 20}, Focused: true},
+			{Name: "DP-2", Geometry: Rect{X: 40, Y: 4, W: 30, H: 16}},
+		},
+		viewer: []string{"imv", "-f"},
+	}
+	closeViewers, err := frozen.show(r, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	closeViewers()
+
+	focus, err := os.ReadFile(filepath.Join(logDir, "focus"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "dispatch focusmonitor DP-2\ndispatch focusmonitor DP-1\n"; string(focus) != want {
+		t.Errorf("got focus order %q, want %q", focus, want)
+	}
+
+	stills := []struct {
+		file   string
+		bounds image.Rectangle
+		color  color.RGBA
+	}{
+		{"frozen-0.png", image.Rect(0, 0, 60, 32), blue},
+		{"frozen-1.png", image.Rect(0, 0, 80, 40), red},
+	}
+	for _, still := range stills {
+		img, _, err := LoadImage(filepath.Join(logDir, still.file))
+		if err != nil {
+			t.Errorf("%s: %v", still.file, err)
+			continue
+		}
+		b := img.Bounds()
+		if b.Size() != still.bounds.Size() {
+			t.Errorf("%s: got size %v, want %v", still.file, b.Size(), still.bounds.Size())
+			continue
+		}
+		for _, p := range []image.Point{b.Min, b.Max.Sub(image.Pt(1, 1))} {
+			if got := color.RGBAModel.Convert(img.At(p.X, p.Y)); got != still.color {
+				t.Errorf("%s: got %v at %v, want %v", still.file, got, p, still.color)
+			}
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// RunGCCommand handles "caplet gc", applying the retention policy
+func RunGCCommand(config Config, args []string) error {
+	fs := flag.NewFlagSet("gc", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "Only report what would be removed")
+	historyPath := fs.String("history", config.HistoryPath, "Folder path to upload history")
+	savePath := fs.String("save", config.SaveDir, "Folder path to upload screenshots/files")
+	fs.Parse(args)
+
+	policy := config.Retention
+	if policy.MaxAgeDays <= 0 && policy.MaxCount <= 0 && policy.MaxSizeMB <= 0 {
+		return fmt.Errorf("no retention policy configured (set maxAgeDays, maxCount or maxSizeMB under \"retention\")")
+	}
+
+	history, err := LoadHistory(*historyPath)
+	if err != nil {
+		return err
+	}
+
+	saveDir := strings.ReplaceAll(*savePath, "$HOME", os.Getenv("HOME"))
+	expired := ExpiredUploads(history, saveDir, policy, time.Now())
+	if !policy.PruneHistory {
+		// Entries stay in the history, so only those with something left to remove matter
+		expired = pendingCleanup(history, expired, policy)
+	}
+	if len(expired) == 0 {
+		fmt.Println("Nothing to clean up.")
+		return nil
+	}
+
+	prefix := ""
+	if *dryRun {
+		prefix = "[dry-run] "
+	}
+
+	// Files still referenced by a newer, retained entry must stay
+	inUse := map[string]bool{}
+	isExpired := map[int]bool{}
+	for _, i := range expired {
+		isExpired[i] = true
+	}
+	for i, upload := range history {
+		if !isExpired[i] {
+			inUse[upload.File] = true
+		}
+	}
+
+	var freed int64
+	removedFiles := map[string]bool{}
+	// Only entries that were fully cleaned up are pruned, so a failed
+	// removal can be retried on the next run
+	var cleaned []int
+	for _, i := range expired {
+		upload := &history[i]
+		ok := true
+
+		if !inUse[upload.File] && !removedFiles[upload.File] && FileExists(upload.File) {
+			var size int64
+			if info, err := os.Stat(upload.File); err == nil {
+				size = info.Size()
+			}
+			fmt.Printf("%sRemoving %s\n", prefix, upload.File)
+			removed := true
+			if !*dryRun {
+				if err := os.Remove(upload.File); err != nil {
+					fmt.Fprintf(os.Stderr, "Failed to remove %s: %v\n", upload.File, err)
+					removed = false
+					ok = false
+				}
+			}
+			if removed {
+				freed += size
+				removedFiles[upload.File] = true
+			}
+		}
+
+		if policy.DeleteRemote && upload.DeletionURL != "" && !upload.Deleted {
+			fmt.Printf("%sDeleting remote upload %s\n", prefix, upload.URL)
+			if !*dryRun {
+				if err := DeleteRemoteUpload(*upload); err != nil {
+					fmt.Fprintf(os.Stderr, "Failed to delete %s: %v\n", upload.URL, err)
+					ok = false
+				} else {
+					upload.Deleted = true
+				}
+			}
+		}
+
+		if ok {
+			cleaned = append(cleaned, i)
+		}
+	}
+
+	if policy.PruneHistory {
+		fmt.Printf("%sPruning %d history entries\n", prefix, len(cleaned))
+		if skipped := len(expired) - len(cleaned); skipped > 0 {
+			fmt.Printf("%sKeeping %d entries that could not be cleaned up\n", prefix, skipped)
+		}
+		history = pruneEntries(history, cleaned)
+	}
+
+	fmt.Printf("%sFreed %.1f MB from %d files\n", prefix, float64(freed)/(1024*1024), len(removedFiles))
+
+	if *dryRun {
+		return nil
+	}
+
+	removeEmptyDirs(saveDir)
+	return WriteHistory(*historyPath, history)
+}
+
+// ExpiredUploads returns the indexes of history entries whose locally saved
+// copy falls outside the retention policy. Only entries saved inside saveDir
+// are considered, so files uploaded from elsewhere are never touched.
+func ExpiredUploads(history []Upload, saveDir string, policy RetentionConfig, now time.Time) []int {
+	var expired []int
+	var kept int
+	var totalSize int64
+	seen := map[string]bool{}
+
+	maxAge := time.Duration(policy.MaxAgeDays) * 24 * time.Hour
+	maxSize := policy.MaxSizeMB * 1024 * 1024
+
+	// Walk from newest to oldest so count and size limits keep recent files
+	for i := len(history) - 1; i >= 0; i-- {
+		upload := history[i]
+		if !isInDir(upload.File, saveDir) {
+			continue
+		}
+
+		isExpired := false
+
+		if timestamp, err := time.Parse(time.RFC3339, upload.Timestamp); err == nil && maxAge > 0 {
+			if now.Sub(timestamp) > maxAge {
+				isExpired = true
+			}
+		}
+
+		// Several entries can share one saved file, only count it once
+		if !seen[upload.File] && !isExpired {
+			if info, err := os.Stat(upload.File); err == nil {
+				kept++
+				totalSize += info.Size()
+			}
+		}
+		seen[upload.File] = true
+
+		if policy.MaxCount > 0 && kept > policy.MaxCount {
+			isExpired = true
+		}
+		if maxSize > 0 && totalSize > maxSize {
+			isExpired = true
+		}
+
+		if isExpired {
+			expired = append([]int{i}, expired...)
+		}
+	}
+
+	return expired
+}
+
+// pendingCleanup keeps the expired entries that still have a local file or
+// a remote upload to delete
+func pendingCleanup(history []Upload, expired []int, policy RetentionConfig) []int {
+	var pending []int
+	for _, i := range expired {
+		upload := history[i]
+		remote := policy.DeleteRemote && upload.DeletionURL != "" && !upload.Deleted
+		if remote || FileExists(upload.File) {
+			pending = append(pending, i)
+		}
+	}
+	return pending
+}
+
+// pruneEntries removes the entries at the given (ascending) indexes
+func pruneEntries(history []Upload, indexes []int) []Upload {
+	remove := map[int]bool{}
+	for _, i := range indexes {
+		remove[i] = true
+	}
+
+	pruned := make([]Upload, 0, len(history)-len(indexes))
+	for i, upload := range history {
+		if !remove[i] {
+			pruned = append(pruned, upload)
+		}
+	}
+
+	return pruned
+}
+
+// isInDir reports whether path lies inside dir
+func isInDir(path string, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+	return rel != "." && !strings.HasPrefix(rel, "..") && !filepath.IsAbs(rel)
+}
+
+// removeEmptyDirs removes empty subdirectories (e.g. old organized months) below root
+func removeEmptyDirs(root string) {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		dir := filepath.Join(root, entry.Name())
+		removeEmptyDirs(dir)
+		if children, err := os.ReadDir(dir); err == nil && len(children) == 0 {
+			os.Remove(dir)
+		}
+	}
+}
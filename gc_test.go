@@ -0,0 +1,32 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestPendingCleanup(t *testing.T) {
+	dir := t.TempDir()
+	present := filepath.Join(dir, "present.png")
+	if err := os.WriteFile(present, []byte("image"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	gone := filepath.Join(dir, "gone.png")
+
+	history := []Upload{
+		{File: present},
+		{File: gone},
+		{File: gone, DeletionURL: "https://host/delete/1"},
+		{File: gone, DeletionURL: "https://host/delete/2", Deleted: true},
+	}
+	expired := []int{0, 1, 2, 3}
+
+	if got, want := pendingCleanup(history, expired, RetentionConfig{}), []int{0}; !slices.Equal(got, want) {
+		t.Errorf("without remote deletion: got %v, want %v", got, want)
+	}
+	if got, want := pendingCleanup(history, expired, RetentionConfig{DeleteRemote: true}), []int{0, 2}; !slices.Equal(got, want) {
+		t.Errorf("with remote deletion: got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/png"
+	"math"
+	"os"
+	"strings"
+)
+
+// Rect is a screen area in global pixel coordinates
+type Rect struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+	W int `json:"width"`
+	H int `json:"height"`
+}
+
+// GrimGeometry formats the rect as "X,Y WxH" like slurp prints it
+func (r Rect) GrimGeometry() string {
+	return fmt.Sprintf("%d,%d %dx%d", r.X, r.Y, r.W, r.H)
+}
+
+// X11Geometry formats the rect as an X11 "WxH+X+Y" geometry
+func (r Rect) X11Geometry() string {
+	return fmt.Sprintf("%dx%d+%d+%d", r.W, r.H, r.X, r.Y)
+}
+
+// scaleRect maps a screen area into the pixels of a capture that starts at
+// origin and has scale pixels per screen unit
+func scaleRect(area Rect, origin image.Point, scale float64) Rect {
+	return Rect{
+		X: int(math.Round(float64(area.X-origin.X) * scale)),
+		Y: int(math.Round(float64(area.Y-origin.Y) * scale)),
+		W: int(math.Round(float64(area.W) * scale)),
+		H: int(math.Round(float64(area.H) * scale)),
+	}
+}
+
+// ParseSlurpGeometry parses slurp's "X,Y WxH" output
+func ParseSlurpGeometry(geometry string) (Rect, error) {
+	var r Rect
+	_, err := fmt.Sscanf(strings.TrimSpace(geometry), "%d,%d %dx%d", &r.X, &r.Y, &r.W, &r.H)
+	if err != nil || r.W <= 0 || r.H <= 0 {
+		return Rect{}, fmt.Errorf("invalid geometry %q, expected X,Y WxH", geometry)
+	}
+	return r, nil
+}
+
+// ParseX11Geometry parses a "WxH+X+Y" geometry
+func ParseX11Geometry(geometry string) (Rect, error) {
+	var r Rect
+	_, err := fmt.Sscanf(strings.TrimSpace(geometry), "%dx%d+%d+%d", &r.W, &r.H, &r.X, &r.Y)
+	if err != nil || r.W <= 0 || r.H <= 0 {
+		return Rect{}, fmt.Errorf("invalid geometry %q, expected WxH+X+Y", geometry)
+	}
+	return r, nil
+}
+
+// SelectArea lets the user drag out a screen area with slurp (Wayland) or
+// slop (X11). It returns false if the selection was cancelled.
+func SelectArea(r Runner) (Rect, bool, error) {
+	if CurrentSession() == SessionX11 {
+		if !haveBinaries(r, "slop") {
+			return Rect{}, false, fmt.Errorf("slop is required to select an area but not found in PATH")
+		}
+		output, err := r.Output("slop", "-f", "%wx%h+%x+%y")
+		if err != nil {
+			return Rect{}, false, nil
+		}
+		area, err := ParseX11Geometry(string(output))
+		return area, err == nil, err
+	}
+
+	if !haveBinaries(r, "slurp") {
+		return Rect{}, false, fmt.Errorf("slurp is required to select an area but not found in PATH")
+	}
+	output, err := r.Output("slurp")
+	if err != nil {
+		return Rect{}, false, nil
+	}
+	area, err := ParseSlurpGeometry(string(output))
+	return area, err == nil, err
+}
+
+// CropImageFile crops the PNG at path to the given area in place.
+// The area is in image pixels, see scaleRect for screen coordinates.
+func CropImageFile(path string, area Rect) error {
+	img, _, err := LoadImage(path)
+	if err != nil {
+		return err
+	}
+
+	cropped, err := cropImage(img, area)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create cropped image: %w", err)
+	}
+	defer file.Close()
+
+	if err := png.Encode(file, cropped); err != nil {
+		return fmt.Errorf("failed to encode cropped image: %w", err)
+	}
+
+	return nil
+}
+
+// cropImage returns the part of img inside area, given in image pixels
+func cropImage(img image.Image, area Rect) (image.Image, error) {
+	cropRect := image.Rect(area.X, area.Y, area.X+area.W, area.Y+area.H).Intersect(img.Bounds())
+	if cropRect.Empty() {
+		return nil, fmt.Errorf("area %s lies outside the captured image", area.X11Geometry())
+	}
+
+	subImager, ok := img.(interface {
+		SubImage(r image.Rectangle) image.Image
+	})
+	if !ok {
+		return nil, fmt.Errorf("image format does not support cropping")
+	}
+
+	return subImager.SubImage(cropRect), nil
+}
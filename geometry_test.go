@@ -0,0 +1,29 @@
+package main
+
+import (
+	"image"
+	"testing"
+)
+
+func TestScaleRect(t *testing.T) {
+	area := Rect{X: 100, Y: 50, W: 200, H: 100}
+
+	tests := []struct {
+		name   string
+		origin image.Point
+		scale  float64
+		want   Rect
+	}{
+		{"unscaled", image.Point{}, 1, Rect{X: 100, Y: 50, W: 200, H: 100}},
+		{"hidpi", image.Point{}, 2, Rect{X: 200, Y: 100, W: 400, H: 200}},
+		{"fractional", image.Point{}, 1.5, Rect{X: 150, Y: 75, W: 300, H: 150}},
+		{"negative origin", image.Pt(-1920, 0), 1, Rect{X: 2020, Y: 50, W: 200, H: 100}},
+		{"negative origin hidpi", image.Pt(-1920, -200), 2, Rect{X: 4040, Y: 500, W: 400, H: 200}},
+	}
+
+	for _, tt := range tests {
+		if got := scaleRect(area, tt.origin, tt.scale); got != tt.want {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Fake binaries for tests of code that runs external tools. Each runner
+// only finds the binaries in its own temporary directory.
+
+// stubBinaries creates executable scripts that succeed for each name and
+// returns a runner that only finds those
+func stubBinaries(t *testing.T, names ...string) ExecRunner {
+	t.Helper()
+	r := ExecRunner{BinDir: t.TempDir()}
+	for _, name := range names {
+		stubScript(t, r, name, "exit 0")
+	}
+	return r
+}
+
+// stubOutput creates a fake binary that prints output
+func stubOutput(t *testing.T, name string, output string) ExecRunner {
+	t.Helper()
+	r := stubBinaries(t)
+	stubPrint(t, r, name, output)
+	return r
+}
+
+// stubPrint adds a fake binary that prints output to r
+func stubPrint(t *testing.T, r ExecRunner, name string, output string) {
+	t.Helper()
+	stubScript(t, r, name, "cat <<'EOF'\n"+output+"\nEOF")
+}
+
+// stubScript adds a fake binary running the shell script body to r
+func stubScript(t *testing.T, r ExecRunner, name string, body string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(r.BinDir, name), []byte("#!/bin/sh\n"+body+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// noSessionBus keeps the portal backend from reaching a real desktop
+func noSessionBus(t *testing.T) {
+	t.Setenv("DBUS_SESSION_BUS_ADDRESS", "unix:path="+filepath.Join(t.TempDir(), "missing"))
+}
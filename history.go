@@ -0,0 +1,365 @@
+package main
+
+import (
+	"embed"
+	"encoding/base64"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+//go:embed web/*.html
+var webFiles embed.FS
+
+// GalleryEntry is a history entry prepared for the HTML gallery
+type GalleryEntry struct {
+	Upload
+	Name      string
+	Date      string
+	Thumbnail template.URL
+	Status    string
+}
+
+// RunHistoryCommand handles "caplet history <subcommand>"
+func RunHistoryCommand(config Config, args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("no subcommand given. Available: export, reupload")
+	}
+
+	switch args[0] {
+	case "export":
+		return runHistoryExport(config, args[1:])
+	case "reupload":
+		return runHistoryReupload(config, args[1:])
+	default:
+		return fmt.Errorf("unknown subcommand %q. Available: export, reupload", args[0])
+	}
+}
+
+func runHistoryExport(config Config, args []string) error {
+	fs := flag.NewFlagSet("history export", flag.ExitOnError)
+	htmlFlag := fs.Bool("html", false, "Export a self-contained HTML gallery")
+	outputFlag := fs.String("o", "caplet-history.html", "Output file path")
+	fromFlag := fs.String("from", "", "Only include uploads on or after this date (YYYY-MM-DD)")
+	toFlag := fs.String("to", "", "Only include uploads on or before this date (YYYY-MM-DD)")
+	historyPath := fs.String("history", config.HistoryPath, "Folder path to upload history")
+	fs.Parse(args)
+
+	if !*htmlFlag {
+		return fmt.Errorf("no export format given, use --html")
+	}
+
+	from, to, err := parseDateRange(*fromFlag, *toFlag)
+	if err != nil {
+		return err
+	}
+
+	history, err := LoadHistory(*historyPath)
+	if err != nil {
+		return err
+	}
+
+	history = FilterHistory(history, from, to)
+	if len(history) == 0 {
+		return fmt.Errorf("no uploads found in the given date range")
+	}
+
+	file, err := os.Create(*outputFlag)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	defer file.Close()
+
+	if err := WriteGallery(file, history, *fromFlag, *toFlag); err != nil {
+		return err
+	}
+
+	fmt.Printf("Exported %d uploads to %s\n", len(history), *outputFlag)
+	return nil
+}
+
+func runHistoryReupload(config Config, args []string) error {
+	fs := flag.NewFlagSet("history reupload", flag.ExitOnError)
+	fromFlag := fs.String("from", "", "Service whose uploads should be moved")
+	toFlag := fs.String("to", "", "Uploader to send the files to")
+	reportFlag := fs.String("report", "", "Write an old,new URL mapping as CSV to this file")
+	dryRun := fs.Bool("dry-run", false, "Only list the uploads that would be moved")
+	historyPath := fs.String("history", config.HistoryPath, "Folder path to upload history")
+	fs.Parse(args)
+
+	if *fromFlag == "" || *toFlag == "" {
+		return fmt.Errorf("both --from and --to are required")
+	}
+
+	target, found := FindUploader(config, *toFlag)
+	if !found {
+		return fmt.Errorf("uploader %q is not configured", *toFlag)
+	}
+
+	history, err := LoadHistory(*historyPath)
+	if err != nil {
+		return err
+	}
+
+	// The source may be given as config key or service name
+	fromName := *fromFlag
+	if source, found := FindUploader(config, *fromFlag); found {
+		fromName = source.Name
+	}
+
+	var mappings []ReuploadMapping
+	var failed int
+	for i, upload := range history {
+		if !strings.EqualFold(upload.Service, fromName) || upload.MovedTo != "" {
+			continue
+		}
+
+		if !FileExists(upload.File) {
+			fmt.Fprintf(os.Stderr, "Skipping %s: local copy %s is missing\n", upload.URL, upload.File)
+			failed++
+			continue
+		}
+
+		if *dryRun {
+			fmt.Printf("[dry-run] %s (%s)\n", upload.URL, upload.File)
+			continue
+		}
+
+		url, err := ReuploadHistoryEntry(config, *historyPath, i, target)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to re-upload %s: %v\n", upload.URL, err)
+			failed++
+			continue
+		}
+
+		mappings = append(mappings, ReuploadMapping{Index: i, OldURL: upload.URL, NewURL: url, File: upload.File})
+	}
+
+	if *dryRun {
+		return nil
+	}
+
+	for _, mapping := range mappings {
+		fmt.Printf("%s -> %s\n", mapping.OldURL, mapping.NewURL)
+	}
+	fmt.Printf("Moved %d uploads from %s to %s, %d skipped or failed\n", len(mappings), fromName, target.Name, failed)
+
+	if *reportFlag != "" {
+		if err := writeReuploadReport(*reportFlag, mappings); err != nil {
+			return err
+		}
+		fmt.Printf("Mapping written to %s\n", *reportFlag)
+	}
+
+	return nil
+}
+
+// ReuploadHistoryEntry uploads the saved copy of history entry index to
+// target and links both ways: the new entry's ReuploadOf points at the old
+// URL and the old entry's MovedTo at the new one.
+func ReuploadHistoryEntry(config Config, historyPath string, index int, target SiteConfig) (string, error) {
+	history, err := LoadHistory(historyPath)
+	if err != nil {
+		return "", err
+	}
+	if index < 0 || index >= len(history) {
+		return "", fmt.Errorf("no history entry with id %d", index)
+	}
+	upload := history[index]
+
+	// Upload from the saved copy's own folder so it is not copied again.
+	// Force skips the dedupe, which would return early without linking the entries.
+	opts := &UploadOptions{Record: Upload{ReuploadOf: upload.URL}, Force: true, StripMetadata: ResolveStripMetadata(config, target)}
+	url, err := UploadFile(upload.File, target, false, historyPath, filepath.Dir(upload.File), false, opts)
+	if err != nil {
+		return "", err
+	}
+
+	// Reload, UploadFile appended the new entry in the meantime
+	history, err = LoadHistory(historyPath)
+	if err != nil {
+		return url, err
+	}
+	if index >= len(history) || history[index].URL != upload.URL {
+		return url, fmt.Errorf("history changed during the re-upload, %s was not marked as moved", upload.URL)
+	}
+	history[index].MovedTo = url
+	if err := WriteHistory(historyPath, history); err != nil {
+		return url, err
+	}
+
+	return url, nil
+}
+
+// ReuploadMapping links a history entry to the URL it was re-uploaded to
+type ReuploadMapping struct {
+	Index  int
+	OldURL string
+	NewURL string
+	File   string
+}
+
+// writeReuploadReport writes the old to new URL mapping as CSV
+func writeReuploadReport(path string, mappings []ReuploadMapping) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create report: %w", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	writer.Write([]string{"old_url", "new_url", "file"})
+	for _, mapping := range mappings {
+		writer.Write([]string{mapping.OldURL, mapping.NewURL, mapping.File})
+	}
+	writer.Flush()
+
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+
+	return nil
+}
+
+// parseDateRange parses the inclusive YYYY-MM-DD bounds of a history filter.
+// Empty bounds are returned as zero times.
+func parseDateRange(fromStr string, toStr string) (time.Time, time.Time, error) {
+	var from, to time.Time
+	var err error
+
+	if fromStr != "" {
+		from, err = time.ParseInLocation(time.DateOnly, fromStr, time.Local)
+		if err != nil {
+			return from, to, fmt.Errorf("invalid -from date: %w", err)
+		}
+	}
+
+	if toStr != "" {
+		to, err = time.ParseInLocation(time.DateOnly, toStr, time.Local)
+		if err != nil {
+			return from, to, fmt.Errorf("invalid -to date: %w", err)
+		}
+		// Include the whole last day
+		to = to.AddDate(0, 0, 1)
+	}
+
+	return from, to, nil
+}
+
+// FilterHistory returns the uploads whose timestamp lies within [from, to).
+// Zero bounds are ignored.
+func FilterHistory(history []Upload, from time.Time, to time.Time) []Upload {
+	var filtered []Upload
+
+	for _, upload := range history {
+		timestamp, err := time.Parse(time.RFC3339, upload.Timestamp)
+		if err != nil {
+			continue
+		}
+		if !from.IsZero() && timestamp.Before(from) {
+			continue
+		}
+		if !to.IsZero() && !timestamp.Before(to) {
+			continue
+		}
+		filtered = append(filtered, upload)
+	}
+
+	return filtered
+}
+
+// DeletionStatus describes whether an upload can still be removed remotely
+func DeletionStatus(upload Upload) string {
+	switch {
+	case upload.Color != "":
+		return "Picked color"
+	case upload.URL == "":
+		return "Saved locally"
+	case upload.Deleted:
+		return "Deleted"
+	case upload.DeletionURL != "":
+		return "Deletable"
+	default:
+		return "No deletion URL"
+	}
+}
+
+// DeleteRemoteUpload removes an upload from its host by visiting its deletion URL
+func DeleteRemoteUpload(upload Upload) error {
+	if upload.DeletionURL == "" {
+		return fmt.Errorf("upload %s has no deletion URL", upload.URL)
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(upload.DeletionURL)
+	if err != nil {
+		return fmt.Errorf("deletion request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("deletion failed with status: %s", resp.Status)
+	}
+
+	return nil
+}
+
+// WriteGallery renders the uploads as a single HTML page with inlined thumbnails
+func WriteGallery(w io.Writer, history []Upload, from string, to string) error {
+	tmpl, err := template.ParseFS(webFiles, "web/gallery.html")
+	if err != nil {
+		return fmt.Errorf("failed to parse gallery template: %w", err)
+	}
+
+	entries := make([]GalleryEntry, 0, len(history))
+	// Newest uploads first
+	for i := len(history) - 1; i >= 0; i-- {
+		upload := history[i]
+		entry := GalleryEntry{
+			Upload: upload,
+			Name:   filepath.Base(upload.File),
+			Date:   upload.Timestamp,
+			Status: DeletionStatus(upload),
+		}
+
+		if timestamp, err := time.Parse(time.RFC3339, upload.Timestamp); err == nil {
+			entry.Date = timestamp.Format("2006-01-02 15:04")
+		}
+
+		if ImageExtensions[strings.ToLower(filepath.Ext(upload.File))] && FileExists(upload.File) {
+			thumb, err := Thumbnail(upload.File, 320)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to create thumbnail for %s: %v\n", upload.File, err)
+			} else {
+				entry.Thumbnail = template.URL("data:image/jpeg;base64," + base64.StdEncoding.EncodeToString(thumb))
+			}
+		}
+
+		entries = append(entries, entry)
+	}
+
+	data := struct {
+		Entries   []GalleryEntry
+		From      string
+		To        string
+		Generated string
+	}{
+		Entries:   entries,
+		From:      from,
+		To:        to,
+		Generated: time.Now().Format("2006-01-02 15:04"),
+	}
+
+	if err := tmpl.Execute(w, data); err != nil {
+		return fmt.Errorf("failed to render gallery: %w", err)
+	}
+
+	return nil
+}
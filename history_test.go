@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReuploadHistoryEntry(t *testing.T) {
+	dir := t.TempDir()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"url":"https://new.example/a.png"}`)
+	}))
+	defer server.Close()
+
+	file := filepath.Join(dir, "a.png")
+	if err := os.WriteFile(file, []byte("image"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	historyPath := filepath.Join(dir, "history.json")
+	old := Upload{URL: "https://old.example/a.png", File: file, Service: "old"}
+	if err := WriteHistory(historyPath, []Upload{{URL: "https://old.example/other.png"}, old}); err != nil {
+		t.Fatal(err)
+	}
+
+	target := SiteConfig{
+		Name:        "new",
+		RequestURL:  server.URL,
+		RequestType: "POST",
+		Regexps:     map[string]string{"url": `"url":"(.+?)"`},
+	}
+	url, err := ReuploadHistoryEntry(Config{}, historyPath, 1, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if url != "https://new.example/a.png" {
+		t.Errorf("got url %q", url)
+	}
+
+	history, err := LoadHistory(historyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 3 {
+		t.Fatalf("got %d history entries, want 3", len(history))
+	}
+	if history[0].MovedTo != "" {
+		t.Errorf("unrelated entry marked as moved to %q", history[0].MovedTo)
+	}
+	if history[1].MovedTo != url {
+		t.Errorf("old entry has MovedTo %q, want %q", history[1].MovedTo, url)
+	}
+	if history[2].ReuploadOf != old.URL || history[2].Service != "new" || history[2].File != file {
+		t.Errorf("new entry %+v does not point back at %s", history[2], old.URL)
+	}
+
+	if _, err := ReuploadHistoryEntry(Config{}, historyPath, 5, target); err == nil {
+		t.Error("expected an error for a missing entry")
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+
+	// Register decoders so image.Decode understands every format we save
+	_ "image/gif"
+	_ "image/png"
+
+	"golang.org/x/image/draw"
+
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp"
+)
+
+// LoadImage decodes the image stored at path
+func LoadImage(path string) (image.Image, string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to open image: %w", err)
+	}
+	defer file.Close()
+
+	img, format, err := image.Decode(file)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	return img, format, nil
+}
+
+// ScaleToFit returns img scaled down so neither side exceeds maxDim.
+// Images that already fit are returned unchanged.
+func ScaleToFit(img image.Image, maxDim int) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= maxDim && height <= maxDim {
+		return img
+	}
+
+	if width >= height {
+		height = max(1, height*maxDim/width)
+		width = maxDim
+	} else {
+		width = max(1, width*maxDim/height)
+		height = maxDim
+	}
+
+	return ScaleImage(img, width, height)
+}
+
+// ScaleImage resizes img to exactly width x height
+func ScaleImage(img image.Image, width int, height int) image.Image {
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, img.Bounds(), draw.Over, nil)
+	return dst
+}
+
+// Thumbnail returns a JPEG encoded preview of the image at path
+func Thumbnail(path string, maxDim int) ([]byte, error) {
+	img, _, err := LoadImage(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := EncodeImage(&buf, ScaleToFit(img, maxDim), "jpeg", 80, png.DefaultCompression); err != nil {
+		return nil, fmt.Errorf("failed to encode thumbnail: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Flatten draws img onto a solid background, removing transparency
+func Flatten(img image.Image, background color.Color) image.Image {
+	bounds := img.Bounds()
+	flat := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(flat, flat.Bounds(), image.NewUniform(background), image.Point{}, draw.Src)
+	draw.Draw(flat, flat.Bounds(), img, bounds.Min, draw.Over)
+	return flat
+}
+
+// EncodeImage writes img as "png" or "jpeg". JPEGs are flattened onto white
+// first so transparent areas don't turn black.
+func EncodeImage(w io.Writer, img image.Image, format string, quality int, compression png.CompressionLevel) error {
+	switch format {
+	case "png":
+		encoder := png.Encoder{CompressionLevel: compression}
+		return encoder.Encode(w, img)
+	case "jpeg", "jpg":
+		if quality <= 0 {
+			quality = jpeg.DefaultQuality
+		}
+		return jpeg.Encode(w, Flatten(img, color.White), &jpeg.Options{Quality: quality})
+	case "webp":
+		return EncodeWebP(w, img)
+	}
+	return fmt.Errorf("unsupported image format %q", format)
+}
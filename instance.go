@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// Long running operations register a pid file so a second caplet
+// invocation can find and signal them (e.g. to cancel or stop).
+
+// pidFilePath returns where the pid file for the named operation lives
+func pidFilePath(name string) string {
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, fmt.Sprintf("caplet-%s.pid", name))
+}
+
+// WritePidFile records the current process as running the named operation.
+// The returned function removes the pid file again.
+func WritePidFile(name string) (func(), error) {
+	path := pidFilePath(name)
+	if err := os.WriteFile(path, []byte(strconv.Itoa(os.Getpid())), 0600); err != nil {
+		return nil, fmt.Errorf("failed to write pid file: %w", err)
+	}
+	return func() { os.Remove(path) }, nil
+}
+
+// RunningInstance returns the pid of the process running the named
+// operation, cleaning up pid files left behind by dead processes
+func RunningInstance(name string) (int, bool) {
+	path := pidFilePath(name)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, false
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid == os.Getpid() {
+		return 0, false
+	}
+
+	// After a crash the pid may since have been reused by an unrelated
+	// process, which must never receive our signals
+	if !isCapletProcess(pid) {
+		os.Remove(path)
+		return 0, false
+	}
+
+	return pid, true
+}
+
+// isCapletProcess reports whether pid is alive and runs the same
+// executable as the current process
+func isCapletProcess(pid int) bool {
+	exe, err := os.Readlink(fmt.Sprintf("/proc/%d/exe", pid))
+	if err != nil {
+		return false
+	}
+	// An upgrade replaces the binary under a running instance
+	exe = strings.TrimSuffix(exe, " (deleted)")
+
+	self, err := os.Executable()
+	if err != nil {
+		return false
+	}
+	if resolved, err := filepath.EvalSymlinks(self); err == nil {
+		self = resolved
+	}
+
+	return exe == self
+}
+
+// SignalInstance sends sig to the process running the named operation
+func SignalInstance(name string, sig syscall.Signal) (bool, error) {
+	pid, running := RunningInstance(name)
+	if !running {
+		return false, nil
+	}
+
+	if err := syscall.Kill(pid, sig); err != nil {
+		return false, fmt.Errorf("failed to signal caplet process %d: %w", pid, err)
+	}
+
+	return true, nil
+}
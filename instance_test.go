@@ -0,0 +1,64 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// TestHelperProcess stands in for a second caplet process when run by the tests below
+func TestHelperProcess(t *testing.T) {
+	if os.Getenv("CAPLET_HELPER_PROCESS") != "1" {
+		return
+	}
+	time.Sleep(time.Minute)
+	os.Exit(0)
+}
+
+func startProcess(t *testing.T, cmd *exec.Cmd) int {
+	t.Helper()
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+	return cmd.Process.Pid
+}
+
+func TestRunningInstance(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+
+	if _, running := RunningInstance("test"); running {
+		t.Error("got a running instance without a pid file")
+	}
+
+	helper := exec.Command(os.Args[0], "-test.run=^TestHelperProcess$")
+	helper.Env = append(os.Environ(), "CAPLET_HELPER_PROCESS=1")
+	caplet := startProcess(t, helper)
+	if err := os.WriteFile(pidFilePath("test"), []byte(strconv.Itoa(caplet)), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if pid, running := RunningInstance("test"); !running || pid != caplet {
+		t.Errorf("got pid %d, running %v, want %d", pid, running, caplet)
+	}
+
+	// A reused pid belongs to some other program and must not be signalled
+	if _, err := exec.LookPath("sleep"); err != nil {
+		t.Skip("sleep is not installed")
+	}
+	other := startProcess(t, exec.Command("sleep", "60"))
+	if err := os.WriteFile(pidFilePath("test"), []byte(strconv.Itoa(other)), 0600); err != nil {
+		t.Fatal(err)
+	}
+	signalled, err := SignalInstance("test", 0)
+	if err != nil || signalled {
+		t.Errorf("got signalled %v, err %v for an unrelated process", signalled, err)
+	}
+	if _, err := os.Stat(pidFilePath("test")); !os.IsNotExist(err) {
+		t.Error("stale pid file was not removed")
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// minShrinkDimension is the smallest longest side FitUploadLimits will
+// downscale an image to before giving up
+const minShrinkDimension = 320
+
+// acceptsType reports whether the service takes files with extension ext
+func acceptsType(service SiteConfig, ext string) bool {
+	if len(service.AcceptedTypes) == 0 {
+		return true
+	}
+	ext = strings.TrimPrefix(strings.ToLower(ext), ".")
+	for _, accepted := range service.AcceptedTypes {
+		accepted = strings.TrimPrefix(strings.ToLower(accepted), ".")
+		if accepted == ext || (accepted == "jpg" && ext == "jpeg") || (accepted == "jpeg" && ext == "jpg") {
+			return true
+		}
+	}
+	return false
+}
+
+// FitUploadLimits makes sure filePath satisfies the service's maxFileSizeMB
+// and acceptedTypes. Images that don't are converted and recompressed into a
+// temporary file, whose path is returned; otherwise filePath is returned as is.
+func FitUploadLimits(filePath string, service SiteConfig) (string, error) {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	limit := int64(service.MaxFileSizeMB * 1024 * 1024)
+	ext := filepath.Ext(filePath)
+	fitsSize := limit <= 0 || info.Size() <= limit
+	if fitsSize && acceptsType(service, ext) {
+		return filePath, nil
+	}
+
+	problem := fmt.Sprintf("%s does not accept %s files", service.Name, ext)
+	if !fitsSize {
+		problem = fmt.Sprintf("%s is %s but %s accepts at most %s", filepath.Base(filePath), formatSize(info.Size()), service.Name, formatSize(limit))
+	}
+
+	if !ImageExtensions[strings.ToLower(ext)] {
+		return "", fmt.Errorf("%s", problem)
+	}
+
+	img, format, err := LoadImage(filePath)
+	if err != nil {
+		return "", fmt.Errorf("%s and the image could not be converted: %w", problem, err)
+	}
+	if format == "gif" {
+		// Recompressing would drop the animation
+		return "", fmt.Errorf("%s and GIFs are not recompressed", problem)
+	}
+
+	// Prefer JPEG since it shrinks the most, PNG only keeps lossless files
+	// acceptable when JPEG is not
+	var targetFormat string
+	switch {
+	case acceptsType(service, ".jpg"):
+		targetFormat = "jpeg"
+	case acceptsType(service, ".png"):
+		targetFormat = "png"
+	default:
+		return "", fmt.Errorf("%s and it accepts neither JPEG nor PNG to convert to", problem)
+	}
+
+	data, err := shrinkImage(img, targetFormat, limit)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", problem, err)
+	}
+
+	tempDir, err := os.MkdirTemp("", "caplet-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	outputPath := filepath.Join(tempDir, strings.TrimSuffix(filepath.Base(filePath), ext)+imageExtension(targetFormat))
+	if err := os.WriteFile(outputPath, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write converted image: %w", err)
+	}
+
+	fmt.Printf("Converted %s to %s (%s) to fit %s's limits\n", filepath.Base(filePath), filepath.Base(outputPath), formatSize(int64(len(data))), service.Name)
+	return outputPath, nil
+}
+
+// shrinkImage encodes img as format, lowering the JPEG quality and then
+// downscaling until the result fits in limit bytes (0 means no limit)
+func shrinkImage(img image.Image, format string, limit int64) ([]byte, error) {
+	qualities := []int{90}
+	if format == "jpeg" {
+		qualities = []int{90, 80, 70, 60, 50, 40}
+	}
+
+	bounds := img.Bounds()
+	longest := max(bounds.Dx(), bounds.Dy())
+	current := img
+	for {
+		for _, quality := range qualities {
+			var buf bytes.Buffer
+			if err := EncodeImage(&buf, current, format, quality, png.BestCompression); err != nil {
+				return nil, fmt.Errorf("failed to encode image: %w", err)
+			}
+			if limit <= 0 || int64(buf.Len()) <= limit {
+				return buf.Bytes(), nil
+			}
+		}
+
+		longest = longest * 3 / 4
+		if longest < minShrinkDimension {
+			return nil, fmt.Errorf("it cannot be made small enough without shrinking it below %dpx", minShrinkDimension)
+		}
+		current = ScaleToFit(img, longest)
+	}
+}
+
+// formatSize formats a byte count for messages
+func formatSize(size int64) string {
+	if size < 1024*1024 {
+		return fmt.Sprintf("%.1f KB", float64(size)/1024)
+	}
+	return fmt.Sprintf("%.1f MB", float64(size)/(1024*1024))
+}
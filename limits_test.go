@@ -0,0 +1,157 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAcceptsType(t *testing.T) {
+	tests := []struct {
+		accepted []string
+		ext      string
+		want     bool
+	}{
+		{nil, ".png", true},
+		{[]string{"png"}, ".png", true},
+		{[]string{"png"}, ".PNG", true},
+		{[]string{".png"}, ".png", true},
+		{[]string{"PNG"}, ".png", true},
+		{[]string{"png"}, ".jpg", false},
+		{[]string{"jpg"}, ".jpeg", true},
+		{[]string{"jpeg"}, ".jpg", true},
+		{[]string{"gif", "webp"}, ".webp", true},
+		{[]string{"gif", "webp"}, ".mp4", false},
+	}
+
+	for _, tt := range tests {
+		if got := acceptsType(SiteConfig{AcceptedTypes: tt.accepted}, tt.ext); got != tt.want {
+			t.Errorf("%v accepting %s: got %v, want %v", tt.accepted, tt.ext, got, tt.want)
+		}
+	}
+}
+
+// writeNoisePNG writes an opaque noise image, which PNG can barely compress
+func writeNoisePNG(t *testing.T, dir string, width int, height int) string {
+	t.Helper()
+	rng := rand.New(rand.NewSource(1))
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for i := 0; i < len(img.Pix); i += 4 {
+		v := rng.Uint32()
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = uint8(v), uint8(v>>8), uint8(v>>16), 255
+	}
+
+	path := filepath.Join(dir, "shot.png")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if err := png.Encode(file, img); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestFitUploadLimits(t *testing.T) {
+	dir := t.TempDir()
+	// About 1.4 MB
+	path := writeNoisePNG(t, dir, 800, 600)
+
+	tests := []struct {
+		name      string
+		service   SiteConfig
+		format    string // Format of the uploaded file, "" for the original
+		maxLonger int    // Longest side the result may have, 0 to keep the size
+		wantErr   string
+	}{
+		{"no limits", SiteConfig{}, "", 0, ""},
+		{"png only", SiteConfig{AcceptedTypes: []string{"png"}}, "", 0, ""},
+		{"jpg only", SiteConfig{AcceptedTypes: []string{"jpg"}}, "jpeg", 0, ""},
+		{"byte size", SiteConfig{MaxFileSizeMB: 0.5}, "jpeg", 0, ""},
+		{"png only with byte size", SiteConfig{AcceptedTypes: []string{"png"}, MaxFileSizeMB: 0.5}, "png", 600, ""},
+		{"cannot shrink enough", SiteConfig{AcceptedTypes: []string{"png"}, MaxFileSizeMB: 0.01}, "", 0, "cannot be made small enough"},
+		{"nothing to convert to", SiteConfig{AcceptedTypes: []string{"gif", "webp"}}, "", 0, "accepts neither JPEG nor PNG"},
+	}
+
+	for _, tt := range tests {
+		tt.service.Name = "host"
+		uploadPath, err := FitUploadLimits(path, tt.service)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: got error %v, want it to contain %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+
+		if tt.format == "" {
+			if uploadPath != path {
+				t.Errorf("%s: got %s, want the original file", tt.name, uploadPath)
+			}
+			continue
+		}
+		t.Cleanup(func() { os.RemoveAll(filepath.Dir(uploadPath)) })
+
+		if uploadPath == path {
+			t.Errorf("%s: the original file was not converted", tt.name)
+			continue
+		}
+		img, format, err := LoadImage(uploadPath)
+		if err != nil || format != tt.format {
+			t.Errorf("%s: got format %q (%v), want %s", tt.name, format, err, tt.format)
+			continue
+		}
+		if want := imageExtension(tt.format); filepath.Ext(uploadPath) != want {
+			t.Errorf("%s: got %s, want a %s file", tt.name, uploadPath, want)
+		}
+		if info, err := os.Stat(uploadPath); err == nil && tt.service.MaxFileSizeMB > 0 && info.Size() > int64(tt.service.MaxFileSizeMB*1024*1024) {
+			t.Errorf("%s: result is %d bytes, over the limit", tt.name, info.Size())
+		}
+		size := img.Bounds().Size()
+		if tt.maxLonger == 0 && size != image.Pt(800, 600) {
+			t.Errorf("%s: got size %v, want it kept at 800x600", tt.name, size)
+		}
+		if tt.maxLonger > 0 && max(size.X, size.Y) > tt.maxLonger {
+			t.Errorf("%s: got size %v, want it scaled to at most %dpx", tt.name, size, tt.maxLonger)
+		}
+	}
+}
+
+func TestFitUploadLimitsUnconvertible(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "clip.gif")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	palette := color.Palette{color.Black, color.White}
+	frame := image.NewPaletted(image.Rect(0, 0, 64, 64), palette)
+	if err := gif.EncodeAll(file, &gif.GIF{Image: []*image.Paletted{frame, frame}, Delay: []int{10, 10}}); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	_, err = FitUploadLimits(path, SiteConfig{Name: "host", AcceptedTypes: []string{"png", "jpg"}})
+	if err == nil || !strings.Contains(err.Error(), "GIFs are not recompressed") {
+		t.Errorf("got error %v, want GIFs are not recompressed", err)
+	}
+
+	text := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(text, []byte(strings.Repeat("x", 2048)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err = FitUploadLimits(text, SiteConfig{Name: "host", MaxFileSizeMB: 0.001})
+	if err == nil || !strings.Contains(err.Error(), "accepts at most") {
+		t.Errorf("got error %v for a text file, want the size problem", err)
+	}
+}
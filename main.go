@@ -2,6 +2,8 @@ package main
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"flag"
 	"fmt"
@@ -14,16 +16,47 @@ import (
 	"path/filepath"
 	"regexp"
 	"runtime"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
+
+	"github.com/godbus/dbus/v5"
 )
 
 // Upload represents an entry in the upload history
 type Upload struct {
-	URL       string `json:"url"`
-	File      string `json:"file"`
-	Timestamp string `json:"timestamp"`
-	Service   string `json:"service"`
+	URL         string `json:"url"`
+	File        string `json:"file"`
+	Timestamp   string `json:"timestamp"`
+	Service     string `json:"service"`
+	DeletionURL string `json:"deletionUrl,omitempty"`
+	Deleted     bool   `json:"deleted,omitempty"`
+	Hash        string `json:"sha256,omitempty"`
+	ReuploadOf  string `json:"reuploadOf,omitempty"`
+	MovedTo     string `json:"movedTo,omitempty"`
+	WindowTitle string `json:"windowTitle,omitempty"`
+	WindowClass string `json:"windowClass,omitempty"`
+	Text        string `json:"text,omitempty"`
+	Color       string `json:"color,omitempty"`
+}
+
+// UploadOptions tweaks how UploadFile behaves.
+// A nil *UploadOptions uses the defaults.
+type UploadOptions struct {
+	// Force uploads the file even if identical content is already live on the service
+	Force bool
+
+	// StripMetadata removes EXIF, XMP and text metadata from the uploaded copy.
+	// The saved copy keeps it.
+	StripMetadata bool
+
+	// Record holds extra history fields that are kept in the saved entry.
+	// URL, File, Timestamp, Service, DeletionURL and Hash are filled in by UploadFile.
+	Record Upload
+
+	// Reused is set by UploadFile when an existing upload was returned instead
+	Reused bool
 }
 
 var NOTIFY_ID string
@@ -138,49 +171,92 @@ func ShortenURL(inputURL string, service SiteConfig, showNotification bool, hist
 }
 
 // UploadFile uploads a file to the specified service
-func UploadFile(filePath string, service SiteConfig, showNotification bool, historyPath string, savePath string, organized bool) (string, error) {
-	fmt.Printf("Uploading to %s...\n", service.Name)
-	// fmt.Println(filePath)
+func UploadFile(filePath string, service SiteConfig, showNotification bool, historyPath string, savePath string, organized bool, opts *UploadOptions) (string, error) {
+	if opts == nil {
+		opts = &UploadOptions{}
+	}
 
-	savePath = strings.ReplaceAll(savePath, "$HOME", os.Getenv("HOME"))
+	hash, err := HashFile(filePath)
+	if err != nil {
+		return "", err
+	}
 
-	// If organized is true, append a year-month subdirectory like "2025-05"
-	if organized {
-		now := time.Now()
-		subDir := now.Format("2006-01")
-		savePath = filepath.Join(savePath, subDir)
+	// Identical content that is still live on this service does not need a new copy
+	if !opts.Force {
+		if existing, found := FindUploadByHash(historyPath, hash, service.Name); found {
+			opts.Reused = true
+			fmt.Printf("Already uploaded to %s, reusing %s\n", service.Name, existing.URL)
+			if showNotification {
+				NOTIFY_ID, err = Notify(fmt.Sprintf("Already uploaded, reusing link: %s", existing.URL), NOTIFY_ID, existing.File)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Failed to show notification: %v\n", err)
+				}
+			}
+			return existing.URL, nil
+		}
 	}
 
+	fmt.Printf("Uploading to %s...\n", service.Name)
+	// fmt.Println(filePath)
+
+	savePath = ResolveSaveDir(savePath, organized)
+
 	// Ensure the savePath directory exists
-	err := os.MkdirAll(savePath, 0755)
+	err = os.MkdirAll(savePath, 0755)
 	if err != nil {
 		return "", fmt.Errorf("failed to create savePath directory: %w", err)
 	}
 
-	// Clone file to savePath
-	srcFile, err := os.Open(filePath)
-	if err != nil {
-		return "", fmt.Errorf("failed to open source file: %w", err)
-	}
-	defer srcFile.Close()
-
 	// Extract the file name
 	fileName := filepath.Base(filePath)
 	dstFilePath := filepath.Join(savePath, fileName)
 
-	dstFile, err := os.Create(dstFilePath)
-	if err != nil {
-		return "", fmt.Errorf("failed to create destination file: %w", err)
+	// Clone file to savePath, unless it is already the saved copy
+	// (e.g. when re-uploading from history)
+	if !sameFile(filePath, dstFilePath) {
+		srcFile, err := os.Open(filePath)
+		if err != nil {
+			return "", fmt.Errorf("failed to open source file: %w", err)
+		}
+		defer srcFile.Close()
+
+		dstFile, err := os.Create(dstFilePath)
+		if err != nil {
+			return "", fmt.Errorf("failed to create destination file: %w", err)
+		}
+		defer dstFile.Close()
+
+		_, err = io.Copy(dstFile, srcFile)
+		if err != nil {
+			return "", fmt.Errorf("failed to copy file: %w", err)
+		}
 	}
-	defer dstFile.Close()
 
-	_, err = io.Copy(dstFile, srcFile)
+	// The saved copy stays untouched, only what is sent is converted
+	uploadPath, err := FitUploadLimits(filePath, service)
 	if err != nil {
-		return "", fmt.Errorf("failed to copy file: %w", err)
+		if showNotification {
+			Notify(fmt.Sprintf("Upload failed: %v", err), NOTIFY_ID, "")
+		}
+		return "", err
+	}
+	if uploadPath != filePath {
+		defer os.RemoveAll(filepath.Dir(uploadPath))
+	}
+
+	if opts.StripMetadata {
+		strippedPath, err := StripFileMetadata(uploadPath)
+		if err != nil {
+			return "", err
+		}
+		if strippedPath != uploadPath {
+			defer os.RemoveAll(filepath.Dir(strippedPath))
+			uploadPath = strippedPath
+		}
 	}
 
 	// Create multipart form data
-	body, contentType, err := createMultipartForm(filePath, service)
+	body, contentType, err := createMultipartForm(uploadPath, service)
 	if err != nil {
 		return "", fmt.Errorf("failed to create form data: %w", err)
 	}
@@ -245,12 +321,15 @@ func UploadFile(filePath string, service SiteConfig, showNotification bool, hist
 	url = regexp.MustCompile(`\\(.)`).ReplaceAllString(url, "$1")
 
 	// Save to upload history
-	err = SaveToHistory(historyPath, Upload{
-		URL:       url,
-		File:      dstFilePath,
-		Timestamp: time.Now().Format(time.RFC3339),
-		Service:   service.Name,
-	})
+	record := opts.Record
+	record.URL = url
+	record.File = dstFilePath
+	record.Timestamp = time.Now().Format(time.RFC3339)
+	record.Service = service.Name
+	record.DeletionURL = ResolveResponseTemplate(service.DeletionURL, responseText)
+	record.Hash = hash
+
+	err = SaveToHistory(historyPath, record)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to save to history: %v\n", err)
 	}
@@ -258,6 +337,67 @@ func UploadFile(filePath string, service SiteConfig, showNotification bool, hist
 	return url, nil
 }
 
+// ResolveSaveDir returns the folder new files are saved to
+func ResolveSaveDir(savePath string, organized bool) string {
+	savePath = strings.ReplaceAll(savePath, "$HOME", os.Getenv("HOME"))
+
+	// If organized is true, append a year-month subdirectory like "2025-05"
+	if organized {
+		now := time.Now()
+		subDir := now.Format("2006-01")
+		savePath = filepath.Join(savePath, subDir)
+	}
+
+	return savePath
+}
+
+// HashFile returns the hex encoded SHA-256 of the file contents
+func HashFile(filePath string) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, file); err != nil {
+		return "", fmt.Errorf("failed to hash file: %w", err)
+	}
+
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// FindUploadByHash looks for a live upload of the same content on the given service
+func FindUploadByHash(historyPath string, hash string, serviceName string) (Upload, bool) {
+	history, err := LoadHistory(historyPath)
+	if err != nil {
+		return Upload{}, false
+	}
+
+	// Prefer the most recent upload
+	for i := len(history) - 1; i >= 0; i-- {
+		upload := history[i]
+		if upload.Hash == hash && upload.Service == serviceName && upload.URL != "" && !upload.Deleted {
+			return upload, true
+		}
+	}
+
+	return Upload{}, false
+}
+
+// sameFile reports whether both paths refer to the same existing file
+func sameFile(a string, b string) bool {
+	infoA, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	infoB, err := os.Stat(b)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(infoA, infoB)
+}
+
 // createMultipartForm creates a multipart form for file upload
 func createMultipartForm(filePath string, service SiteConfig) (io.Reader, string, error) {
 	file, err := os.Open(filePath)
@@ -303,28 +443,51 @@ func createMultipartForm(filePath string, service SiteConfig) (io.Reader, string
 
 // SaveToHistory saves upload information to history file
 func SaveToHistory(historyPath string, upload Upload) error {
-	historyPath = strings.ReplaceAll(historyPath, "$HOME", os.Getenv("HOME"))
-
-	if err := os.MkdirAll(historyPath, 0755); err != nil {
-		return fmt.Errorf("failed to create history directory: %w", err)
+	history, err := LoadHistory(historyPath)
+	if err != nil {
+		// If file exists but is corrupt, start with empty history
+		history = []Upload{}
 	}
 
+	// Add new upload to history
+	history = append(history, upload)
+
+	return WriteHistory(historyPath, history)
+}
+
+// LoadHistory reads all entries from the history file.
+// A missing history file is not an error and yields an empty history.
+func LoadHistory(historyPath string) ([]Upload, error) {
+	historyPath = strings.ReplaceAll(historyPath, "$HOME", os.Getenv("HOME"))
 	historyFile := filepath.Join(historyPath, "history.json")
 
 	var history []Upload
 
-	// Read existing history if file exists
-	if data, err := os.ReadFile(historyFile); err == nil {
-		if err := json.Unmarshal(data, &history); err != nil {
-			// If file exists but is corrupt, start with empty history
-			history = []Upload{}
+	data, err := os.ReadFile(historyFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return history, nil
 		}
+		return nil, fmt.Errorf("failed to read history file: %w", err)
 	}
 
-	// Add new upload to history
-	history = append(history, upload)
+	if err := json.Unmarshal(data, &history); err != nil {
+		return nil, fmt.Errorf("failed to parse history file: %w", err)
+	}
+
+	return history, nil
+}
+
+// WriteHistory replaces the history file with the given entries
+func WriteHistory(historyPath string, history []Upload) error {
+	historyPath = strings.ReplaceAll(historyPath, "$HOME", os.Getenv("HOME"))
+
+	if err := os.MkdirAll(historyPath, 0755); err != nil {
+		return fmt.Errorf("failed to create history directory: %w", err)
+	}
+
+	historyFile := filepath.Join(historyPath, "history.json")
 
-	// Write updated history
 	data, err := json.MarshalIndent(history, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal history: %w", err)
@@ -362,12 +525,71 @@ func Notify(message string, id string, icon string) (string, error) {
 	return strings.TrimSpace(string(output)), nil
 }
 
+// CloseNotification removes a notification shown by Notify
+func CloseNotification(id string) error {
+	if runtime.GOOS != "linux" {
+		return nil // Only supported on Linux
+	}
+
+	notificationID, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		return fmt.Errorf("invalid notification id %q: %w", id, err)
+	}
+
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return fmt.Errorf("failed to connect to session bus: %w", err)
+	}
+	defer conn.Close()
+
+	obj := conn.Object("org.freedesktop.Notifications", "/org/freedesktop/Notifications")
+	if err := obj.Call("org.freedesktop.Notifications.CloseNotification", 0, uint32(notificationID)).Err; err != nil {
+		return fmt.Errorf("failed to close notification: %w", err)
+	}
+
+	return nil
+}
+
+// captureModes are the modes that capture the screen and honour -delay
+var captureModes = map[string]bool{
+	"s": true, "select": true,
+	"fs": true, "fullscreen": true,
+	"w": true, "window": true,
+	"m": true, "monitor": true,
+	"last-region": true,
+	"scroll":      true,
+	"color":       true,
+	"ocr":         true,
+	"scan":        true,
+	"record":      true,
+}
+
+// regionModes are the modes that select a region, which -geometry replaces
+var regionModes = map[string]bool{
+	"s": true, "select": true,
+	"ocr":    true,
+	"scan":   true,
+	"scroll": true,
+	"color":  true,
+}
+
+// redactModes are the screenshot modes whose capture can be redacted
+var redactModes = map[string]bool{
+	"s": true, "select": true,
+	"fs": true, "fullscreen": true,
+	"w": true, "window": true,
+	"m": true, "monitor": true,
+	"last-region": true,
+	"scroll":      true,
+}
+
 func main() {
 	// Define command-line flags
 	var filePath string
 	var inputURL string
 	var url string
 	var err error
+	uploadOpts := &UploadOptions{}
 
 	config, err := LoadConfig()
 	if err != nil {
@@ -375,15 +597,90 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Subcommands are dispatched before the regular flags are parsed
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "history":
+			if err := RunHistoryCommand(config, os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "history: %v\n", err)
+				os.Exit(1)
+			}
+			os.Exit(0)
+		case "gc":
+			if err := RunGCCommand(config, os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "gc: %v\n", err)
+				os.Exit(1)
+			}
+			os.Exit(0)
+		case "backends":
+			if err := RunBackendsCommand(config, os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "backends: %v\n", err)
+				os.Exit(1)
+			}
+			os.Exit(0)
+		case "monitors":
+			if err := RunMonitorsCommand(config, os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "monitors: %v\n", err)
+				os.Exit(1)
+			}
+			os.Exit(0)
+		case "cancel":
+			if err := RunCancelCommand(config, os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "cancel: %v\n", err)
+				os.Exit(1)
+			}
+			os.Exit(0)
+		case "ui":
+			if err := RunUICommand(config, os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "ui: %v\n", err)
+				os.Exit(1)
+			}
+			os.Exit(0)
+		}
+	}
+
 	helpFlag := flag.Bool("help", false, "Help command")
-	modeFlag := flag.String("mode", "", "Set the mode.\nf/file: Upload a file.\nfs/fullscreen: Screenshoot entire screen\ns/select: Select screen region\nc/clipboard: Upload clipboard contents\nu/url: Shorten url")
+	modeFlag := flag.String("mode", "", "Set the mode.\nf/file: Upload a file.\nfs/fullscreen: Screenshoot entire screen\ns/select: Select screen region\nw/window: Screenshot the focused window\nm/monitor: Screenshot a single monitor (see -output)\nlast-region: Capture the last selected region again\nscroll: Capture a scrolling region as one tall image, run again to stop\nrecord: Record the screen, run again to stop\nocr: Copy the text in a screen region\ncolor: Pick a color from the screen\nscan: Read a QR code or barcode in a screen region\nc/clipboard: Upload clipboard contents\nu/url: Shorten url")
 	sxcuFlag := flag.String("sxcu", "", "Path to the .sxcu config file")
 	notifyFlag := flag.Bool("notify", true, "Show desktop notifications")
 	clipFlag := flag.Bool("clip", true, "Copy resulting URL to clipboard.")
+	outputFlag := flag.String("output", "focused", "Monitor for monitor mode: name, index, focused or under-cursor")
+	selectFlag := flag.Bool("select", false, "Record a selected region instead of the whole screen (record mode)")
+	formatFlag := flag.String("format", config.Recording.Format, "Recording format: mp4, webm or gif")
+	audioFlag := flag.Bool("audio", config.Recording.Audio, "Record audio along with the screen")
+	autoScrollFlag := flag.Bool("auto-scroll", config.Scroll.Auto, "Scroll automatically with ydotool/xdotool (scroll mode)")
+	delayFlag := flag.Int("delay", 0, "Seconds to wait before capturing (cancel with 'caplet cancel')")
+	backendFlag := flag.String("backend", config.ScreenshotBackend, "Screenshot backend to use (see 'caplet backends')")
+	cursorFlag := flag.Bool("cursor", config.Cursor, "Include the mouse cursor in screenshots")
+	geometryFlag := flag.String("geometry", "", "Capture this area instead of selecting one in region modes (WxH+X+Y or a region name from the config)")
+	freezeFlag := flag.Bool("freeze", config.Freeze, "Freeze the screen while selecting a region")
+	var redactFlag RectList
+	flag.Var(&redactFlag, "redact", "Area of the capture to redact as WxH+X+Y (repeatable)")
+	redactSelectFlag := flag.Bool("redact-select", false, "Select areas to redact after capturing")
+	redactStyleFlag := flag.String("redact-style", config.Redaction.Style, "How to redact: pixelate or black")
+	keepOriginalFlag := flag.Bool("keep-original", config.Redaction.KeepOriginal, "Keep the unredacted capture in the save folder")
+	langFlag := flag.String("lang", config.OCR.Language, "Tesseract language(s) for ocr mode, e.g. eng or eng+deu")
+	colorFormatFlag := flag.String("color-format", config.ColorFormat, "Color notation to copy in color mode: hex, rgb or hsl")
+	uploadTextFlag := flag.Bool("upload-text", config.OCR.UploadText, "Upload the recognised text in ocr mode")
+	qrFlag := flag.Bool("qr", config.QR.Enabled, "Show the resulting URL as a QR code")
+	qrCopyFlag := flag.Bool("qr-copy", config.QR.CopyImage, "Copy the QR code image instead of the URL (with -qr)")
+	scanActionFlag := flag.String("scan-action", config.ScanAction, "What to do with a scanned code in scan mode: copy, open or shorten")
+	editFlag := flag.Bool("edit", config.Annotate, "Annotate captures in the browser before uploading")
+	processFlag := flag.Bool("process", true, "Run the configured post-processing pipeline on screenshots")
+	stripFlag := flag.Bool("strip", config.StripMetadata, "Remove EXIF and other metadata from uploaded files")
+	forceFlag := flag.Bool("force", false, "Upload even if the same file was already uploaded to the service")
 	historyPath := flag.String("history", config.HistoryPath, "Folder path to upload history")
 	savePath := flag.String("save", config.SaveDir, "Folder path to upload screenshots/files")
 	flag.Parse()
 
+	uploadOpts.Force = *forceFlag
+	captureOpts := CaptureOptions{
+		Cursor:  *cursorFlag,
+		Freeze:  *freezeFlag,
+		Backend: *backendFlag,
+		Order:   config.BackendOrder,
+	}
+
 	if *helpFlag {
 		flag.Usage()
 		os.Exit(0)
@@ -400,9 +697,114 @@ func main() {
 		os.Exit(0)
 	}
 
+	// A fixed area replaces the interactive selection in region modes
+	var fixedArea *Rect
+	if *geometryFlag != "" {
+		if !regionModes[*modeFlag] {
+			fmt.Fprintf(os.Stderr, "-geometry only works with region modes (select, ocr, scan, scroll, color)\n")
+			os.Exit(1)
+		}
+		area, err := ResolveRegion(config, *geometryFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid -geometry: %v\n", err)
+			os.Exit(1)
+		}
+		fixedArea = &area
+	}
+
+	if (len(redactFlag) > 0 || *redactSelectFlag) && !redactModes[*modeFlag] {
+		fmt.Fprintf(os.Stderr, "-redact and -redact-select only work with screenshot modes (select, fullscreen, window, monitor, last-region, scroll)\n")
+		os.Exit(1)
+	}
+	if *redactSelectFlag && *modeFlag == "scroll" {
+		// The stitched image is taller than the screen the areas are picked on
+		fmt.Fprintf(os.Stderr, "-redact-select does not work with scrolling captures, use -redact\n")
+		os.Exit(1)
+	}
+
+	// A second invocation finishes the running capture, before any countdown
+	switch *modeFlag {
+	case "scroll":
+		stopped, err := StopScrollCapture()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to stop scrolling capture: %v\n", err)
+			os.Exit(1)
+		}
+		if stopped {
+			fmt.Println("Finishing scrolling capture...")
+			os.Exit(0)
+		}
+	case "record":
+		stopped, err := StopRecording()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to stop recording: %v\n", err)
+			os.Exit(1)
+		}
+		if stopped {
+			fmt.Println("Stopping recording...")
+			os.Exit(0)
+		}
+	}
+
+	if *delayFlag > 0 && captureModes[*modeFlag] {
+		proceed, err := Countdown(*delayFlag, *notifyFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Countdown failed: %v\n", err)
+			os.Exit(1)
+		}
+		if !proceed {
+			os.Exit(0)
+		}
+	}
+
+	// Captured images are redacted and post-processed before upload
+	postProcess := captureModes[*modeFlag]
+	// Recognized text goes to the text uploader instead of the file uploader
+	textUpload := false
+
 	switch *modeFlag {
 	case "s", "select":
-		filePath, err = TakeScreenshot(true)
+		if fixedArea != nil {
+			captureOpts.Geometry = fixedArea
+		} else if *redactSelectFlag {
+			// Redactions are selected on screen, so the region's position must be known
+			area, selected, err := SelectArea(defaultRunner)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "failed to select region: %v\n", err)
+				os.Exit(1)
+			}
+			if !selected {
+				fmt.Println("Screenshot operation cancelled by user.")
+				os.Exit(0)
+			}
+			rememberRegion(area)
+			captureOpts.Geometry = &area
+		} else {
+			captureOpts.Region = true
+		}
+		filePath, err = TakeScreenshot(captureOpts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to take screenshot: %v\n", err)
+			os.Exit(1)
+		}
+
+		exists := FileExists(filePath)
+		if !exists {
+			fmt.Println("Screenshot operation cancelled by user.")
+			os.Exit(0)
+		}
+		go PlayCaptured()
+
+	case "last-region":
+		area, err := LoadLastRegion()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to load last region: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Capturing last region %s\n", area.X11Geometry())
+		captureOpts.Geometry = &area
+
+		filePath, err = TakeScreenshot(captureOpts)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "failed to take screenshot: %v\n", err)
 			os.Exit(1)
@@ -416,7 +818,57 @@ func main() {
 		go PlayCaptured()
 
 	case "fs", "fullscreen":
-		filePath, err = TakeScreenshot(false)
+		filePath, err = TakeScreenshot(captureOpts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to take screenshot: %v\n", err)
+			os.Exit(1)
+		}
+
+		exists := FileExists(filePath)
+		if !exists {
+			fmt.Println("Screenshot operation cancelled by user.")
+			os.Exit(0)
+		}
+		go PlayCaptured()
+
+	case "w", "window":
+		window, err := GetActiveWindow(defaultRunner)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to find the active window: %v\n", err)
+			os.Exit(1)
+		}
+		captureOpts.Geometry = &window.Geometry
+		uploadOpts.Record.WindowTitle = window.Title
+		uploadOpts.Record.WindowClass = window.Class
+
+		filePath, err = TakeScreenshot(captureOpts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to take screenshot: %v\n", err)
+			os.Exit(1)
+		}
+
+		exists := FileExists(filePath)
+		if !exists {
+			fmt.Println("Screenshot operation cancelled by user.")
+			os.Exit(0)
+		}
+		go PlayCaptured()
+
+	case "m", "monitor":
+		monitors, err := ListMonitors(defaultRunner)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to list monitors: %v\n", err)
+			os.Exit(1)
+		}
+		monitor, err := SelectMonitor(defaultRunner, monitors, *outputFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to select monitor: %v\n", err)
+			os.Exit(1)
+		}
+		captureOpts.Geometry = &monitor.Geometry
+		captureOpts.Output = monitor.Name
+
+		filePath, err = TakeScreenshot(captureOpts)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "failed to take screenshot: %v\n", err)
 			os.Exit(1)
@@ -429,6 +881,232 @@ func main() {
 		}
 		go PlayCaptured()
 
+	case "scroll":
+		captureOpts.Geometry = fixedArea
+		scroll := config.Scroll
+		scroll.Auto = *autoScrollFlag
+		filePath, err = ScrollCapture(captureOpts, scroll, *notifyFlag)
+		if err != nil {
+			go PlayError()
+			fmt.Fprintf(os.Stderr, "failed to capture scrolling region: %v\n", err)
+			os.Exit(1)
+		}
+
+		if !FileExists(filePath) {
+			fmt.Println("Screenshot operation cancelled by user.")
+			os.Exit(0)
+		}
+
+	case "ocr":
+		postProcess = false
+		captureOpts.Region = fixedArea == nil
+		captureOpts.Geometry = fixedArea
+		filePath, err = TakeScreenshot(captureOpts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to take screenshot: %v\n", err)
+			os.Exit(1)
+		}
+
+		if !FileExists(filePath) {
+			fmt.Println("Screenshot operation cancelled by user.")
+			os.Exit(0)
+		}
+		go PlayCaptured()
+
+		text, err := RecognizeText(defaultRunner, filePath, *langFlag)
+		if err != nil {
+			go PlayError()
+			fmt.Fprintf(os.Stderr, "OCR failed: %v\n", err)
+			os.Exit(1)
+		}
+		if text == "" {
+			fmt.Println("No text found.")
+			if *notifyFlag {
+				NOTIFY_ID, _ = Notify("No text found", NOTIFY_ID, "")
+			}
+			os.Exit(0)
+		}
+		fmt.Println(text)
+
+		if *clipFlag {
+			if err := CopyToClipboard(text, "text"); err != nil {
+				go PlayError()
+				fmt.Fprintf(os.Stderr, "Failed to copy text to clipboard: %v\n", err)
+				os.Exit(1)
+			}
+		}
+
+		// Keep the screenshot and its text in history so it can be searched
+		savedPath, err := SaveLocalCapture(filePath, *savePath, config.Organized, *historyPath, Upload{Text: text})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to save screenshot: %v\n", err)
+			os.Exit(1)
+		}
+
+		if *uploadTextFlag {
+			// The text file goes through the regular upload below
+			filePath, err = WriteTextFile(text, "ocr")
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				os.Exit(1)
+			}
+			uploadOpts.Record.Text = text
+			textUpload = true
+			break
+		}
+
+		if *notifyFlag {
+			NOTIFY_ID, err = Notify(fmt.Sprintf("Copied %d characters of text", len([]rune(text))), NOTIFY_ID, savedPath)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to show notification: %v\n", err)
+			}
+		}
+		os.Exit(0)
+
+	case "color":
+		postProcess = false
+		colorFormat := strings.ToLower(*colorFormatFlag)
+		if colorFormat == "" {
+			colorFormat = "hex"
+		}
+		if !slices.Contains(colorFormats, colorFormat) {
+			fmt.Fprintf(os.Stderr, "unknown color format %q (%s)\n", *colorFormatFlag, strings.Join(colorFormats, ", "))
+			os.Exit(1)
+		}
+
+		captureOpts.Geometry = fixedArea
+		picked, ok, err := PickColor(defaultRunner, captureOpts)
+		if err != nil {
+			go PlayError()
+			fmt.Fprintf(os.Stderr, "failed to pick color: %v\n", err)
+			os.Exit(1)
+		}
+		if !ok {
+			fmt.Println("Color picking cancelled by user.")
+			os.Exit(0)
+		}
+		go PlayCaptured()
+
+		var formatted []string
+		for _, format := range colorFormats {
+			value, _ := FormatColor(picked, format)
+			formatted = append(formatted, value)
+			fmt.Printf("%s: %s\n", strings.ToUpper(format), value)
+		}
+		value, _ := FormatColor(picked, colorFormat)
+
+		if *clipFlag {
+			if err := CopyToClipboard(value, "text"); err != nil {
+				go PlayError()
+				fmt.Fprintf(os.Stderr, "Failed to copy color to clipboard: %v\n", err)
+				os.Exit(1)
+			}
+		}
+
+		record := Upload{
+			Timestamp: time.Now().Format(time.RFC3339),
+			Service:   LocalService,
+			Color:     formatted[0],
+			Text:      strings.Join(formatted, "\n"),
+		}
+		if err := SaveToHistory(*historyPath, record); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to save to history: %v\n", err)
+		}
+
+		if *notifyFlag {
+			swatch, err := WriteColorSwatch(picked)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+			}
+			NOTIFY_ID, err = Notify("Copied color "+value, NOTIFY_ID, swatch)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to show notification: %v\n", err)
+			}
+		}
+		os.Exit(0)
+
+	case "scan":
+		postProcess = false
+		captureOpts.Region = fixedArea == nil
+		captureOpts.Geometry = fixedArea
+		filePath, err = TakeScreenshot(captureOpts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to take screenshot: %v\n", err)
+			os.Exit(1)
+		}
+
+		if !FileExists(filePath) {
+			fmt.Println("Screenshot operation cancelled by user.")
+			os.Exit(0)
+		}
+		go PlayCaptured()
+
+		result, err := ScanImageFile(filePath)
+		os.Remove(filePath)
+		if err != nil {
+			go PlayError()
+			fmt.Fprintf(os.Stderr, "Scan failed: %v\n", err)
+			if *notifyFlag {
+				Notify(fmt.Sprintf("Scan failed: %v", err), NOTIFY_ID, "")
+			}
+			os.Exit(1)
+		}
+		fmt.Printf("%s: %s\n", result.Format, result.Text)
+
+		action := *scanActionFlag
+		if (action == "open" || action == "shorten") && !isWebURL(result.Text) {
+			fmt.Printf("Not a URL, copying it instead of using -scan-action %s\n", action)
+			action = "copy"
+		}
+
+		switch action {
+		case "shorten":
+			// Continue with the regular URL shortening below
+			inputURL = result.Text
+			filePath = ""
+		case "open":
+			if err := OpenURL(defaultRunner, result.Text); err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to open URL: %v\n", err)
+				os.Exit(1)
+			}
+			if *notifyFlag {
+				NOTIFY_ID, _ = Notify(fmt.Sprintf("Opened %s", result.Text), NOTIFY_ID, "")
+			}
+			os.Exit(0)
+		case "", "copy":
+			if err := CopyToClipboard(result.Text, "text"); err != nil {
+				go PlayError()
+				fmt.Fprintf(os.Stderr, "Failed to copy to clipboard: %v\n", err)
+				os.Exit(1)
+			}
+			if *notifyFlag {
+				NOTIFY_ID, _ = Notify(fmt.Sprintf("Copied %s: %s", result.Format, result.Text), NOTIFY_ID, "")
+			}
+			os.Exit(0)
+		default:
+			fmt.Fprintf(os.Stderr, "unknown scan action %q (copy, open or shorten)\n", action)
+			os.Exit(1)
+		}
+
+	case "record":
+		// Recordings are videos, the image post-processing does not apply
+		postProcess = false
+
+		recording := config.Recording
+		recording.Format = *formatFlag
+		recording.Audio = *audioFlag
+		filePath, err = RecordScreen(*selectFlag, recording, *notifyFlag)
+		if err != nil {
+			go PlayError()
+			fmt.Fprintf(os.Stderr, "failed to record screen: %v\n", err)
+			os.Exit(1)
+		}
+
+		if !FileExists(filePath) {
+			fmt.Println("Recording cancelled by user.")
+			os.Exit(0)
+		}
+
 	case "f", "file":
 		if len(flag.Args()) < 1 {
 			fmt.Fprintf(os.Stderr, "no file provided!")
@@ -466,6 +1144,67 @@ func main() {
 		os.Exit(0)
 	}
 
+	if filePath != "" && postProcess && (len(redactFlag) > 0 || *redactSelectFlag) {
+		areas := []Rect(redactFlag)
+		if *redactSelectFlag {
+			// Fullscreen captures cover every monitor of the layout
+			captured := captureOpts.Geometry
+			if captured == nil {
+				captured = screenArea(defaultRunner)
+			}
+			origin, scale := captureOrigin(filePath, captured)
+			selected, err := SelectRedactions(defaultRunner, origin, scale, *notifyFlag)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to select redactions: %v\n", err)
+				os.Exit(1)
+			}
+			areas = append(areas, selected...)
+		}
+
+		if len(areas) > 0 {
+			if *keepOriginalFlag {
+				originalPath, err := SaveOriginal(filePath, *savePath, config.Organized)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Failed to keep unredacted copy: %v\n", err)
+					os.Exit(1)
+				}
+				fmt.Printf("Unredacted copy kept at %s\n", originalPath)
+			}
+			// The unredacted capture is overwritten so it never gets uploaded
+			if err := RedactImageFile(filePath, areas, *redactStyleFlag); err != nil {
+				go PlayError()
+				fmt.Fprintf(os.Stderr, "Failed to redact screenshot: %v\n", err)
+				os.Exit(1)
+			}
+		}
+	}
+
+	if filePath != "" && postProcess && *editFlag {
+		proceed, err := EditImage(filePath, *notifyFlag)
+		if err != nil {
+			go PlayError()
+			fmt.Fprintf(os.Stderr, "Failed to open editor: %v\n", err)
+			os.Exit(1)
+		}
+		if !proceed {
+			fmt.Println("Upload cancelled.")
+			os.Exit(0)
+		}
+	}
+
+	if filePath != "" && postProcess && *processFlag && len(config.Pipeline) > 0 {
+		processed, err := RunPipeline(filePath, config.Pipeline)
+		if err != nil {
+			go PlayError()
+			fmt.Fprintf(os.Stderr, "Failed to process screenshot: %v\n", err)
+			os.Exit(1)
+		}
+		if processed != filePath {
+			os.Remove(filePath)
+			filePath = processed
+		}
+	}
+
 	if filePath != "" {
 		ext := filepath.Ext(filePath)
 		isImage := ImageExtensions[ext]
@@ -481,6 +1220,8 @@ func main() {
 				}
 				// fmt.Println("Image copied to clipboard (due to -clip flag).") // Optional: confirmation message
 			}
+		} else if textUpload && config.DefaultTextUpload != "" {
+			serviceName = config.DefaultTextUpload
 		} else {
 			serviceName = config.DefaultFileUpload
 		}
@@ -491,7 +1232,14 @@ func main() {
 			if found { // Service is configured and exists
 				// Proceed with upload
 				fmt.Printf("Attempting to upload %s...\n", filePath)
-				url, err = UploadFile(filePath, service, *notifyFlag, *historyPath, *savePath, config.Organized)
+				uploadOpts.StripMetadata = ResolveStripMetadata(config, service)
+				// An explicit -strip beats the uploader's setting
+				flag.Visit(func(f *flag.Flag) {
+					if f.Name == "strip" {
+						uploadOpts.StripMetadata = *stripFlag
+					}
+				})
+				url, err = UploadFile(filePath, service, *notifyFlag, *historyPath, *savePath, config.Organized, uploadOpts)
 				if err != nil {
 					go PlayError()
 					fmt.Fprintf(os.Stderr, "Upload failed: %v\n", err)
@@ -601,16 +1349,41 @@ func main() {
 			}
 		}
 
+		notifyIcon := filePath
+		if *qrFlag {
+			qrPath, errQR := WriteQRCode(url)
+			if errQR != nil {
+				fmt.Fprintf(os.Stderr, "Failed to create QR code: %v\n", errQR)
+			} else {
+				notifyIcon = qrPath
+				if isTerminal(os.Stdout) {
+					if code, errQR := TerminalQRCode(url); errQR == nil {
+						fmt.Print(code)
+					}
+				}
+				if *clipFlag && *qrCopyFlag {
+					if errCp := CopyToClipboard(qrPath, ".png"); errCp != nil {
+						fmt.Fprintf(os.Stderr, "Failed to copy QR code to clipboard: %v\n", errCp)
+					}
+				}
+			}
+		}
+
 		action := "Uploaded"
 		if inputURL != "" { // If original input was a URL, it was shortened.
 			action = "Shortened"
+		} else if uploadOpts.Reused {
+			action = "Reused"
 		}
 		fmt.Printf("%s: %s\n", action, url)
 
 		if *notifyFlag {
 			notifyMessage := fmt.Sprintf("%s successful: %s", action, url)
+			if uploadOpts.Reused {
+				notifyMessage = fmt.Sprintf("Already uploaded, reusing link: %s", url)
+			}
 			var notifyErr error
-			NOTIFY_ID, notifyErr = Notify(notifyMessage, NOTIFY_ID, filePath)
+			NOTIFY_ID, notifyErr = Notify(notifyMessage, NOTIFY_ID, notifyIcon)
 			if notifyErr != nil {
 				go PlayError()
 				fmt.Fprintf(os.Stderr, "Failed to show notification: %v\n", notifyErr)
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+)
+
+// Metadata is removed by rewriting the container byte by byte, so the image
+// data itself is never re-encoded and stays bit-identical. The EXIF
+// orientation is the one tag that survives: without it photos taken on a
+// phone would be shown sideways.
+
+// StripFileMetadata writes a copy of filePath without EXIF, XMP, IPTC and
+// text metadata to a temporary file and returns its path. Files without
+// metadata, and formats we don't understand, are returned unchanged.
+func StripFileMetadata(filePath string) (string, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file: %w", err)
+	}
+
+	stripped, changed, err := StripMetadata(data)
+	if err != nil {
+		return "", fmt.Errorf("failed to strip metadata from %s: %w", filepath.Base(filePath), err)
+	}
+	if !changed {
+		return filePath, nil
+	}
+
+	tempDir, err := os.MkdirTemp("", "caplet-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	outputPath := filepath.Join(tempDir, filepath.Base(filePath))
+	if err := os.WriteFile(outputPath, stripped, 0644); err != nil {
+		return "", fmt.Errorf("failed to write stripped file: %w", err)
+	}
+
+	return outputPath, nil
+}
+
+// StripMetadata removes metadata from JPEG, PNG and WebP data.
+// It reports whether anything was removed.
+func StripMetadata(data []byte) ([]byte, bool, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xFF, 0xD8}):
+		return stripJPEG(data)
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return stripPNG(data)
+	case len(data) >= 12 && string(data[0:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		return stripWebP(data)
+	}
+	return data, false, nil
+}
+
+// --- EXIF orientation
+
+// exifOrientation returns the Orientation tag (1-8) of a TIFF structured
+// EXIF block, or 0 if it has none
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 0
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 0
+	}
+	count := int(order.Uint16(tiff[ifd:]))
+	for i := range count {
+		entry := ifd + 2 + 12*i
+		if entry+12 > len(tiff) {
+			return 0
+		}
+		// Tag 0x0112 of type SHORT, the value sits in the first two bytes
+		if order.Uint16(tiff[entry:]) == 0x0112 && order.Uint16(tiff[entry+2:]) == 3 {
+			if orientation := int(order.Uint16(tiff[entry+8:])); orientation >= 1 && orientation <= 8 {
+				return orientation
+			}
+			return 0
+		}
+	}
+	return 0
+}
+
+// orientationEXIF returns the EXIF block to keep in place of tiff: one
+// holding only its orientation, or nil if the image is upright anyway
+func orientationEXIF(tiff []byte) []byte {
+	orientation := exifOrientation(tiff)
+	if orientation <= 1 {
+		return nil
+	}
+
+	// Big endian header, IFD0 at offset 8 with a single entry and no next IFD
+	return []byte{'M', 'M', 0, 42, 0, 0, 0, 8, 0, 1, 0x01, 0x12, 0, 3, 0, 0, 0, 1, 0, byte(orientation), 0, 0, 0, 0, 0, 0}
+}
+
+// --- JPEG
+
+// exifHeader starts the EXIF APP1 segment of a JPEG
+const exifHeader = "Exif\x00\x00"
+
+// stripJPEG drops APP1 (EXIF, XMP), APP13 (IPTC) and COM segments.
+// APP0 (JFIF) and APP2 (ICC profile) are kept since they affect rendering,
+// and the EXIF segment is replaced by one holding only the orientation.
+func stripJPEG(data []byte) ([]byte, bool, error) {
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:2])
+	changed := false
+	keptOrientation := false
+
+	pos := 2
+	for pos < len(data) {
+		if data[pos] != 0xFF {
+			return nil, false, fmt.Errorf("invalid JPEG marker at offset %d", pos)
+		}
+		// Markers may be padded with extra 0xFF bytes
+		markerStart := pos
+		for pos < len(data) && data[pos] == 0xFF {
+			pos++
+		}
+		if pos >= len(data) {
+			return nil, false, fmt.Errorf("truncated JPEG")
+		}
+		marker := data[pos]
+		pos++
+
+		// Start of scan: the entropy coded data runs to the end of the file
+		if marker == 0xDA {
+			out.Write(data[markerStart:])
+			return out.Bytes(), changed, nil
+		}
+		// Markers without a length field
+		if marker == 0xD8 || marker == 0xD9 || marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7) {
+			out.Write(data[markerStart:pos])
+			continue
+		}
+
+		if pos+2 > len(data) {
+			return nil, false, fmt.Errorf("truncated JPEG")
+		}
+		length := int(binary.BigEndian.Uint16(data[pos:]))
+		end := pos + length
+		if length < 2 || end > len(data) {
+			return nil, false, fmt.Errorf("invalid JPEG segment length at offset %d", pos)
+		}
+
+		segment := data[markerStart:end]
+		payload := data[pos+2 : end]
+		switch {
+		case marker == 0xE1 && bytes.HasPrefix(payload, []byte(exifHeader)) && !keptOrientation:
+			exif := orientationEXIF(payload[len(exifHeader):])
+			if exif == nil {
+				changed = true
+				break
+			}
+			keptOrientation = true
+			minimal := binary.BigEndian.AppendUint16([]byte{0xFF, 0xE1}, uint16(2+len(exifHeader)+len(exif)))
+			minimal = append(append(minimal, exifHeader...), exif...)
+			if !bytes.Equal(segment, minimal) {
+				changed = true
+			}
+			out.Write(minimal)
+		case marker == 0xE1 || marker == 0xED || marker == 0xFE:
+			changed = true
+		default:
+			out.Write(segment)
+		}
+		pos = end
+	}
+
+	return out.Bytes(), changed, nil
+}
+
+// --- PNG
+
+// strippedPNGChunks are the ancillary chunks holding text, EXIF and timestamps
+var strippedPNGChunks = map[string]bool{
+	"tEXt": true,
+	"zTXt": true,
+	"iTXt": true,
+	"eXIf": true,
+	"tIME": true,
+}
+
+func stripPNG(data []byte) ([]byte, bool, error) {
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:8])
+	changed := false
+
+	pos := 8
+	for pos < len(data) {
+		// length, type, data, CRC
+		if pos+12 > len(data) {
+			return nil, false, fmt.Errorf("truncated PNG chunk at offset %d", pos)
+		}
+		length := int(binary.BigEndian.Uint32(data[pos:]))
+		end := pos + 12 + length
+		if length < 0 || end > len(data) {
+			return nil, false, fmt.Errorf("invalid PNG chunk length at offset %d", pos)
+		}
+
+		chunkType := string(data[pos+4 : pos+8])
+		switch {
+		case chunkType == "eXIf":
+			exif := orientationEXIF(data[pos+8 : pos+8+length])
+			if exif == nil {
+				changed = true
+				break
+			}
+			minimal := binary.BigEndian.AppendUint32(nil, uint32(len(exif)))
+			minimal = append(append(minimal, "eXIf"...), exif...)
+			minimal = binary.BigEndian.AppendUint32(minimal, crc32.ChecksumIEEE(minimal[4:]))
+			if !bytes.Equal(data[pos:end], minimal) {
+				changed = true
+			}
+			out.Write(minimal)
+		case strippedPNGChunks[chunkType]:
+			changed = true
+		default:
+			out.Write(data[pos:end])
+		}
+		pos = end
+
+		if chunkType == "IEND" {
+			break
+		}
+	}
+
+	return out.Bytes(), changed, nil
+}
+
+// --- WebP
+
+// VP8X flags announcing EXIF and XMP chunks
+const (
+	webpFlagEXIF = 0x08
+	webpFlagXMP  = 0x04
+)
+
+func stripWebP(data []byte) ([]byte, bool, error) {
+	riffEnd := 8 + int(binary.LittleEndian.Uint32(data[4:]))
+	if riffEnd > len(data) {
+		return nil, false, fmt.Errorf("truncated WebP")
+	}
+
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:12])
+	changed := false
+	keptOrientation := false
+	vp8xFlags := -1
+
+	pos := 12
+	for pos < riffEnd {
+		// FourCC, size, payload padded to an even length
+		if pos+8 > riffEnd {
+			return nil, false, fmt.Errorf("truncated WebP chunk at offset %d", pos)
+		}
+		size := int(binary.LittleEndian.Uint32(data[pos+4:]))
+		end := pos + 8 + size + size%2
+		if end > riffEnd {
+			// Some encoders omit the final padding byte
+			end = pos + 8 + size
+			if end > riffEnd {
+				return nil, false, fmt.Errorf("invalid WebP chunk size at offset %d", pos)
+			}
+		}
+
+		fourCC := string(data[pos : pos+4])
+		switch fourCC {
+		case "EXIF":
+			// Some writers keep the JPEG style header in front of the TIFF data
+			exif := orientationEXIF(bytes.TrimPrefix(data[pos+8:pos+8+size], []byte(exifHeader)))
+			if exif == nil || keptOrientation {
+				changed = true
+				break
+			}
+			keptOrientation = true
+			minimal := binary.LittleEndian.AppendUint32([]byte("EXIF"), uint32(len(exif)))
+			minimal = append(minimal, exif...)
+			if !bytes.Equal(data[pos:end], minimal) {
+				changed = true
+			}
+			out.Write(minimal)
+		case "XMP ":
+			changed = true
+		case "VP8X":
+			if size < 1 {
+				return nil, false, fmt.Errorf("invalid VP8X chunk")
+			}
+			vp8xFlags = out.Len() + 8
+			out.Write(data[pos:end])
+		default:
+			out.Write(data[pos:end])
+		}
+		pos = end
+	}
+
+	if !changed {
+		return data, false, nil
+	}
+
+	result := out.Bytes()
+	// The extended header must no longer announce the removed chunks
+	if vp8xFlags >= 0 {
+		result[vp8xFlags] &^= webpFlagXMP
+		if !keptOrientation {
+			result[vp8xFlags] &^= webpFlagEXIF
+		}
+	}
+	binary.LittleEndian.PutUint32(result[4:], uint32(len(result)-8))
+
+	return result, true, nil
+}
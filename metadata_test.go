@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"testing"
+
+	"golang.org/x/image/webp"
+)
+
+func jpegSegment(marker byte, payload []byte) []byte {
+	segment := binary.BigEndian.AppendUint16([]byte{0xFF, marker}, uint16(2+len(payload)))
+	return append(segment, payload...)
+}
+
+func pngChunk(chunkType string, data []byte) []byte {
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(data)))
+	chunk = append(append(chunk, chunkType...), data...)
+	return binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+}
+
+func riffChunk(fourCC string, data []byte) []byte {
+	chunk := binary.LittleEndian.AppendUint32([]byte(fourCC), uint32(len(data)))
+	chunk = append(chunk, data...)
+	if len(data)%2 == 1 {
+		chunk = append(chunk, 0)
+	}
+	return chunk
+}
+
+// cameraEXIF is a little endian TIFF block with a camera make and orientation
+func cameraEXIF(orientation int) []byte {
+	tiff := []byte{'I', 'I', 42, 0, 8, 0, 0, 0, 2, 0}
+	// Make, ASCII, 4 bytes stored inline
+	tiff = append(tiff, 0x0F, 0x01, 2, 0, 4, 0, 0, 0, 'A', 'c', 'm', 0)
+	// Orientation, SHORT
+	tiff = append(tiff, 0x12, 0x01, 3, 0, 1, 0, 0, 0, byte(orientation), 0, 0, 0)
+	return append(tiff, 0, 0, 0, 0)
+}
+
+func concat(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+func testImage() image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, 8, 4))
+	for x := range 8 {
+		img.SetNRGBA(x, x%4, color.NRGBA{R: 200, G: uint8(x * 30), A: 255})
+	}
+	return img
+}
+
+func TestStripJPEG(t *testing.T) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, testImage(), nil); err != nil {
+		t.Fatal(err)
+	}
+	soi, rest := buf.Bytes()[:2], buf.Bytes()[2:]
+
+	jfif := jpegSegment(0xE0, []byte("JFIF\x00\x01\x01\x00\x00\x01\x00\x01\x00\x00"))
+	icc := jpegSegment(0xE2, []byte("ICC_PROFILE\x00\x01\x01profile"))
+	xmp := jpegSegment(0xE1, []byte("http://ns.adobe.com/xap/1.0/\x00<x:xmpmeta/>"))
+	iptc := jpegSegment(0xED, []byte("Photoshop 3.0\x008BIM"))
+	comment := jpegSegment(0xFE, []byte("taken at home"))
+	exif := func(orientation int) []byte {
+		return jpegSegment(0xE1, concat([]byte(exifHeader), cameraEXIF(orientation)))
+	}
+	minimal := func(orientation int) []byte {
+		return jpegSegment(0xE1, concat([]byte(exifHeader), orientationEXIF(cameraEXIF(orientation))))
+	}
+
+	tests := []struct {
+		name        string
+		input       []byte
+		want        []byte
+		changed     bool
+		orientation int
+	}{
+		{"all metadata", concat(soi, jfif, exif(1), xmp, icc, iptc, comment, rest), concat(soi, jfif, icc, rest), true, 0},
+		{"rotated", concat(soi, jfif, exif(6), xmp, comment, rest), concat(soi, jfif, minimal(6), rest), true, 6},
+		{"only orientation", concat(soi, minimal(8), rest), concat(soi, minimal(8), rest), false, 8},
+		{"nothing to strip", concat(soi, jfif, icc, rest), concat(soi, jfif, icc, rest), false, 0},
+		{"padded markers", concat(soi, []byte{0xFF}, comment, rest), concat(soi, rest), true, 0},
+	}
+
+	for _, tt := range tests {
+		got, changed, err := StripMetadata(tt.input)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if changed != tt.changed || !bytes.Equal(got, tt.want) {
+			t.Errorf("%s: got changed %v and %x, want %v and %x", tt.name, changed, got, tt.changed, tt.want)
+			continue
+		}
+		if _, err := jpeg.Decode(bytes.NewReader(got)); err != nil {
+			t.Errorf("%s: result does not decode: %v", tt.name, err)
+		}
+
+		orientation := 0
+		if i := bytes.Index(got, []byte(exifHeader)); i >= 0 {
+			orientation = exifOrientation(got[i+len(exifHeader):])
+		}
+		if orientation != tt.orientation {
+			t.Errorf("%s: got orientation %d, want %d", tt.name, orientation, tt.orientation)
+		}
+	}
+}
+
+func TestStripPNG(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, testImage()); err != nil {
+		t.Fatal(err)
+	}
+	// Signature and IHDR, then the image data
+	head, rest := buf.Bytes()[:33], buf.Bytes()[33:]
+
+	text := pngChunk("tEXt", []byte("Author\x00someone"))
+	itxt := pngChunk("iTXt", []byte("Comment\x00\x00\x00\x00\x00hello"))
+	ztxt := pngChunk("zTXt", []byte("Software\x00\x00x\x9c\x03\x00\x00\x00\x00\x01"))
+	timestamp := pngChunk("tIME", []byte{0x07, 0xEA, 10, 18, 12, 0, 0})
+	gamma := pngChunk("gAMA", []byte{0, 0, 0xB1, 0x8F})
+
+	tests := []struct {
+		name        string
+		input       []byte
+		want        []byte
+		changed     bool
+		orientation int
+	}{
+		{"all metadata", concat(head, gamma, text, itxt, ztxt, pngChunk("eXIf", cameraEXIF(1)), timestamp, rest), concat(head, gamma, rest), true, 0},
+		{"rotated", concat(head, pngChunk("eXIf", cameraEXIF(3)), text, rest), concat(head, pngChunk("eXIf", orientationEXIF(cameraEXIF(3))), rest), true, 3},
+		{"nothing to strip", concat(head, gamma, rest), concat(head, gamma, rest), false, 0},
+	}
+
+	for _, tt := range tests {
+		got, changed, err := StripMetadata(tt.input)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if changed != tt.changed || !bytes.Equal(got, tt.want) {
+			t.Errorf("%s: got changed %v and %x, want %v and %x", tt.name, changed, got, tt.changed, tt.want)
+			continue
+		}
+		if _, err := png.Decode(bytes.NewReader(got)); err != nil {
+			t.Errorf("%s: result does not decode: %v", tt.name, err)
+		}
+
+		orientation := 0
+		if i := bytes.Index(got, []byte("eXIf")); i >= 0 {
+			orientation = exifOrientation(got[i+4:])
+		}
+		if orientation != tt.orientation {
+			t.Errorf("%s: got orientation %d, want %d", tt.name, orientation, tt.orientation)
+		}
+	}
+}
+
+func TestStripWebP(t *testing.T) {
+	var buf bytes.Buffer
+	if err := EncodeWebP(&buf, testImage()); err != nil {
+		t.Fatal(err)
+	}
+	bitstream := buf.Bytes()[12:]
+
+	// VP8X with the given flags for an 8x4 canvas
+	vp8x := func(flags byte) []byte {
+		return riffChunk("VP8X", []byte{flags, 0, 0, 0, 7, 0, 0, 3, 0, 0})
+	}
+	riff := func(chunks ...[]byte) []byte {
+		body := concat(chunks...)
+		return concat([]byte("RIFF"), binary.LittleEndian.AppendUint32(nil, uint32(4+len(body))), []byte("WEBP"), body)
+	}
+	// An odd length, so the chunk carries a padding byte
+	xmp := riffChunk("XMP ", []byte("<x:xmpmeta/>!"))
+
+	tests := []struct {
+		name        string
+		input       []byte
+		want        []byte
+		changed     bool
+		orientation int
+	}{
+		{"all metadata", riff(vp8x(webpFlagEXIF|webpFlagXMP), bitstream, riffChunk("EXIF", cameraEXIF(1)), xmp), riff(vp8x(0), bitstream), true, 0},
+		{"rotated", riff(vp8x(webpFlagEXIF|webpFlagXMP), bitstream, riffChunk("EXIF", cameraEXIF(5)), xmp),
+			riff(vp8x(webpFlagEXIF), bitstream, riffChunk("EXIF", orientationEXIF(cameraEXIF(5)))), true, 5},
+		{"JPEG style EXIF header", riff(vp8x(webpFlagEXIF), bitstream, riffChunk("EXIF", concat([]byte(exifHeader), cameraEXIF(1)))), riff(vp8x(0), bitstream), true, 0},
+		{"nothing to strip", riff(bitstream), riff(bitstream), false, 0},
+	}
+
+	for _, tt := range tests {
+		got, changed, err := StripMetadata(tt.input)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if changed != tt.changed || !bytes.Equal(got, tt.want) {
+			t.Errorf("%s: got changed %v and %x, want %v and %x", tt.name, changed, got, tt.changed, tt.want)
+			continue
+		}
+		if _, err := webp.Decode(bytes.NewReader(got)); err != nil {
+			t.Errorf("%s: result does not decode: %v", tt.name, err)
+		}
+
+		orientation := 0
+		if i := bytes.Index(got, []byte("EXIF")); i >= 0 {
+			orientation = exifOrientation(got[i+8:])
+		}
+		if orientation != tt.orientation {
+			t.Errorf("%s: got orientation %d, want %d", tt.name, orientation, tt.orientation)
+		}
+	}
+}
+
+func TestStripMetadataTruncated(t *testing.T) {
+	pngHead := concat([]byte("\x89PNG\r\n\x1a\n"), pngChunk("IHDR", make([]byte, 13)))
+
+	tests := []struct {
+		name    string
+		input   []byte
+		wantErr string
+	}{
+		{"JPEG marker without type", []byte{0xFF, 0xD8, 0xFF, 0xFF}, "truncated JPEG"},
+		{"JPEG without segment length", []byte{0xFF, 0xD8, 0xFF, 0xE1, 0x00}, "truncated JPEG"},
+		{"JPEG segment past the end", concat([]byte{0xFF, 0xD8}, jpegSegment(0xE1, []byte("Exif\x00\x00II*\x00"))[:8]), "invalid JPEG segment length"},
+		{"JPEG garbage between segments", []byte{0xFF, 0xD8, 0x00}, "invalid JPEG marker"},
+		{"PNG chunk header cut off", concat(pngHead, []byte{0, 0, 0, 4, 't', 'E'}), "truncated PNG chunk"},
+		{"PNG chunk past the end", concat(pngHead, pngChunk("tEXt", []byte("Author\x00someone"))[:12]), "invalid PNG chunk length"},
+		{"WebP shorter than RIFF size", concat([]byte("RIFF\x40\x00\x00\x00WEBP"), riffChunk("VP8L", []byte{0x2f})), "truncated WebP"},
+		{"WebP chunk past the end", concat([]byte("RIFF\x0e\x00\x00\x00WEBP"), []byte("EXIF\x20\x00\x00\x00II")), "invalid WebP chunk size"},
+		{"WebP chunk header cut off", concat([]byte("RIFF\x08\x00\x00\x00WEBP"), []byte("EXIF")), "truncated WebP chunk"},
+	}
+
+	for _, tt := range tests {
+		_, _, err := StripMetadata(tt.input)
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: got error %v, want it to contain %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,351 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"image"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Monitor is a connected output and its place in the screen layout
+type Monitor struct {
+	Name     string
+	Geometry Rect
+	Focused  bool
+}
+
+// ListMonitors enumerates the active outputs
+func ListMonitors(r Runner) ([]Monitor, error) {
+	if CurrentSession() == SessionX11 {
+		return xrandrMonitors(r)
+	}
+
+	switch {
+	case os.Getenv("HYPRLAND_INSTANCE_SIGNATURE") != "" && haveBinaries(r, "hyprctl"):
+		return hyprlandMonitors(r)
+	case os.Getenv("SWAYSOCK") != "" && haveBinaries(r, "swaymsg"):
+		return swayMonitors(r)
+	case haveBinaries(r, "wlr-randr"):
+		return wlrRandrMonitors(r)
+	}
+
+	return nil, fmt.Errorf("listing monitors on Wayland needs hyprctl, swaymsg or wlr-randr")
+}
+
+// screenArea returns the bounding box of all monitors, which is what a
+// fullscreen capture covers, or nil if the monitors can't be listed
+func screenArea(r Runner) *Rect {
+	monitors, err := ListMonitors(r)
+	if err != nil || len(monitors) == 0 {
+		return nil
+	}
+
+	area := layoutArea(monitors)
+	return &area
+}
+
+// layoutArea returns the bounding box of monitors
+func layoutArea(monitors []Monitor) Rect {
+	bounds := image.Rectangle{}
+	for i, monitor := range monitors {
+		g := monitor.Geometry
+		rect := image.Rect(g.X, g.Y, g.X+g.W, g.Y+g.H)
+		if i == 0 {
+			bounds = rect
+		} else {
+			bounds = bounds.Union(rect)
+		}
+	}
+
+	return Rect{X: bounds.Min.X, Y: bounds.Min.Y, W: bounds.Dx(), H: bounds.Dy()}
+}
+
+// SelectMonitor picks a monitor by name, index, "focused" or "under-cursor"
+func SelectMonitor(r Runner, monitors []Monitor, spec string) (Monitor, error) {
+	if len(monitors) == 0 {
+		return Monitor{}, fmt.Errorf("no monitors found")
+	}
+
+	switch spec {
+	case "", "focused":
+		for _, monitor := range monitors {
+			if monitor.Focused {
+				return monitor, nil
+			}
+		}
+		// Without focus information fall back to the pointer
+		if monitor, err := SelectMonitor(r, monitors, "under-cursor"); err == nil {
+			return monitor, nil
+		}
+		return monitors[0], nil
+
+	case "under-cursor":
+		x, y, err := CursorPosition(r)
+		if err != nil {
+			return Monitor{}, err
+		}
+		for _, monitor := range monitors {
+			g := monitor.Geometry
+			if x >= g.X && x < g.X+g.W && y >= g.Y && y < g.Y+g.H {
+				return monitor, nil
+			}
+		}
+		return Monitor{}, fmt.Errorf("no monitor contains the cursor at %d,%d", x, y)
+	}
+
+	if index, err := strconv.Atoi(spec); err == nil {
+		if index < 0 || index >= len(monitors) {
+			return Monitor{}, fmt.Errorf("monitor index %d out of range (0-%d)", index, len(monitors)-1)
+		}
+		return monitors[index], nil
+	}
+
+	for _, monitor := range monitors {
+		if monitor.Name == spec {
+			return monitor, nil
+		}
+	}
+
+	return Monitor{}, fmt.Errorf("no monitor named %q, run 'caplet monitors' to list them", spec)
+}
+
+// CursorPosition returns the pointer position in global coordinates
+func CursorPosition(r Runner) (int, int, error) {
+	if CurrentSession() == SessionX11 {
+		if !haveBinaries(r, "xdotool") {
+			return 0, 0, fmt.Errorf("finding the cursor on X11 needs xdotool")
+		}
+		output, err := r.Output("xdotool", "getmouselocation", "--shell")
+		if err != nil {
+			return 0, 0, fmt.Errorf("xdotool getmouselocation failed: %w", err)
+		}
+		values := parseKeyValues(string(output), "=")
+		x, errX := strconv.Atoi(values["X"])
+		y, errY := strconv.Atoi(values["Y"])
+		if errX != nil || errY != nil {
+			return 0, 0, fmt.Errorf("unexpected xdotool output: %s", output)
+		}
+		return x, y, nil
+	}
+
+	if os.Getenv("HYPRLAND_INSTANCE_SIGNATURE") != "" && haveBinaries(r, "hyprctl") {
+		// hyprctl cursorpos prints "X, Y"
+		output, err := r.Output("hyprctl", "cursorpos")
+		if err != nil {
+			return 0, 0, fmt.Errorf("hyprctl cursorpos failed: %w", err)
+		}
+		var x, y int
+		if _, err := fmt.Sscanf(strings.TrimSpace(string(output)), "%d, %d", &x, &y); err != nil {
+			return 0, 0, fmt.Errorf("unexpected hyprctl output: %s", output)
+		}
+		return x, y, nil
+	}
+
+	return 0, 0, fmt.Errorf("this compositor does not expose the cursor position, use -output focused")
+}
+
+// --- Hyprland
+
+func hyprlandMonitors(r Runner) ([]Monitor, error) {
+	output, err := r.Output("hyprctl", "monitors", "-j")
+	if err != nil {
+		return nil, fmt.Errorf("hyprctl monitors failed: %w", err)
+	}
+
+	var outputs []struct {
+		Name      string  `json:"name"`
+		X         int     `json:"x"`
+		Y         int     `json:"y"`
+		Width     int     `json:"width"`
+		Height    int     `json:"height"`
+		Scale     float64 `json:"scale"`
+		Transform int     `json:"transform"`
+		Focused   bool    `json:"focused"`
+	}
+	if err := json.Unmarshal(output, &outputs); err != nil {
+		return nil, fmt.Errorf("failed to parse hyprctl output: %w", err)
+	}
+
+	var monitors []Monitor
+	for _, o := range outputs {
+		// Width and height are in pixels, the layout uses logical coordinates
+		scale := o.Scale
+		if scale <= 0 {
+			scale = 1
+		}
+		width, height := o.Width, o.Height
+		// Transforms 1, 3, 5 and 7 rotate by 90 or 270 degrees
+		if o.Transform%2 == 1 {
+			width, height = height, width
+		}
+		monitors = append(monitors, Monitor{
+			Name:     o.Name,
+			Geometry: Rect{X: o.X, Y: o.Y, W: int(float64(width) / scale), H: int(float64(height) / scale)},
+			Focused:  o.Focused,
+		})
+	}
+
+	return monitors, nil
+}
+
+// --- sway
+
+func swayMonitors(r Runner) ([]Monitor, error) {
+	output, err := r.Output("swaymsg", "-t", "get_outputs")
+	if err != nil {
+		return nil, fmt.Errorf("swaymsg get_outputs failed: %w", err)
+	}
+
+	var outputs []struct {
+		Name    string `json:"name"`
+		Active  bool   `json:"active"`
+		Focused bool   `json:"focused"`
+		Rect    struct {
+			X      int `json:"x"`
+			Y      int `json:"y"`
+			Width  int `json:"width"`
+			Height int `json:"height"`
+		} `json:"rect"`
+	}
+	if err := json.Unmarshal(output, &outputs); err != nil {
+		return nil, fmt.Errorf("failed to parse sway outputs: %w", err)
+	}
+
+	var monitors []Monitor
+	for _, o := range outputs {
+		if !o.Active {
+			continue
+		}
+		monitors = append(monitors, Monitor{
+			Name:     o.Name,
+			Geometry: Rect{X: o.Rect.X, Y: o.Rect.Y, W: o.Rect.Width, H: o.Rect.Height},
+			Focused:  o.Focused,
+		})
+	}
+
+	return monitors, nil
+}
+
+// --- wlr-randr (other wlroots compositors)
+
+func wlrRandrMonitors(r Runner) ([]Monitor, error) {
+	output, err := r.Output("wlr-randr", "--json")
+	if err != nil {
+		return nil, fmt.Errorf("wlr-randr failed: %w", err)
+	}
+
+	var outputs []struct {
+		Name     string `json:"name"`
+		Enabled  bool   `json:"enabled"`
+		Position struct {
+			X int `json:"x"`
+			Y int `json:"y"`
+		} `json:"position"`
+		Scale     float64 `json:"scale"`
+		Transform string  `json:"transform"`
+		Modes     []struct {
+			Width   int  `json:"width"`
+			Height  int  `json:"height"`
+			Current bool `json:"current"`
+		} `json:"modes"`
+	}
+	if err := json.Unmarshal(output, &outputs); err != nil {
+		return nil, fmt.Errorf("failed to parse wlr-randr output: %w", err)
+	}
+
+	var monitors []Monitor
+	for _, o := range outputs {
+		if !o.Enabled {
+			continue
+		}
+		for _, mode := range o.Modes {
+			if !mode.Current {
+				continue
+			}
+			width, height := mode.Width, mode.Height
+			// Rotated outputs swap their sides in the layout
+			if o.Transform == "90" || o.Transform == "270" || o.Transform == "flipped-90" || o.Transform == "flipped-270" {
+				width, height = height, width
+			}
+			scale := o.Scale
+			if scale <= 0 {
+				scale = 1
+			}
+			monitors = append(monitors, Monitor{
+				Name:     o.Name,
+				Geometry: Rect{X: o.Position.X, Y: o.Position.Y, W: int(float64(width) / scale), H: int(float64(height) / scale)},
+			})
+		}
+	}
+
+	return monitors, nil
+}
+
+// --- X11
+
+func xrandrMonitors(r Runner) ([]Monitor, error) {
+	if !haveBinaries(r, "xrandr") {
+		return nil, fmt.Errorf("listing monitors on X11 needs xrandr")
+	}
+
+	output, err := r.Output("xrandr", "--listactivemonitors")
+	if err != nil {
+		return nil, fmt.Errorf("xrandr failed: %w", err)
+	}
+
+	// " 0: +*DP-1 2560/597x1440/336+0+0  DP-1"
+	re := regexp.MustCompile(`^\s*\d+:\s+\S+\s+(\d+)/\d+x(\d+)/\d+\+(-?\d+)\+(-?\d+)\s+(\S+)`)
+
+	var monitors []Monitor
+	for _, line := range strings.Split(string(output), "\n") {
+		m := re.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		w, _ := strconv.Atoi(m[1])
+		h, _ := strconv.Atoi(m[2])
+		x, _ := strconv.Atoi(m[3])
+		y, _ := strconv.Atoi(m[4])
+		monitors = append(monitors, Monitor{Name: m[5], Geometry: Rect{X: x, Y: y, W: w, H: h}})
+	}
+
+	// X11 has no focused output, use the one holding the active window
+	if window, err := x11ActiveWindow(r); err == nil {
+		cx := window.Geometry.X + window.Geometry.W/2
+		cy := window.Geometry.Y + window.Geometry.H/2
+		for i, monitor := range monitors {
+			g := monitor.Geometry
+			if cx >= g.X && cx < g.X+g.W && cy >= g.Y && cy < g.Y+g.H {
+				monitors[i].Focused = true
+				break
+			}
+		}
+	}
+
+	return monitors, nil
+}
+
+// RunMonitorsCommand handles "caplet monitors", listing the outputs
+func RunMonitorsCommand(config Config, args []string) error {
+	fs := flag.NewFlagSet("monitors", flag.ExitOnError)
+	fs.Parse(args)
+
+	monitors, err := ListMonitors(defaultRunner)
+	if err != nil {
+		return err
+	}
+
+	for i, monitor := range monitors {
+		marker := " "
+		if monitor.Focused {
+			marker = "*"
+		}
+		fmt.Printf("%s %d  %-12s %s\n", marker, i, monitor.Name, monitor.Geometry.X11Geometry())
+	}
+
+	return nil
+}
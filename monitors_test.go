@@ -0,0 +1,30 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestHyprlandMonitors(t *testing.T) {
+	r := stubOutput(t, "hyprctl", `[
+  {"name": "DP-1", "x": 0, "y": 0, "width": 3840, "height": 2160, "scale": 2, "transform": 0, "focused": true},
+  {"name": "DP-2", "x": 1920, "y": 0, "width": 2560, "height": 1440, "scale": 1, "transform": 1, "focused": false},
+  {"name": "HDMI-A-1", "x": -1080, "y": 0, "width": 1920, "height": 1080, "scale": 1, "transform": 7, "focused": false},
+  {"name": "eDP-1", "x": 0, "y": 1080, "width": 1920, "height": 1080, "scale": 1, "transform": 2, "focused": false}
+]`)
+
+	monitors, err := hyprlandMonitors(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Monitor{
+		{Name: "DP-1", Geometry: Rect{X: 0, Y: 0, W: 1920, H: 1080}, Focused: true},
+		{Name: "DP-2", Geometry: Rect{X: 1920, Y: 0, W: 1440, H: 2560}},
+		{Name: "HDMI-A-1", Geometry: Rect{X: -1080, Y: 0, W: 1080, H: 1920}},
+		{Name: "eDP-1", Geometry: Rect{X: 0, Y: 1080, W: 1920, H: 1080}},
+	}
+	if !slices.Equal(monitors, want) {
+		t.Errorf("got %+v, want %+v", monitors, want)
+	}
+}
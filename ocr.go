@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// LocalService is the service name of history entries that were saved
+// locally without being uploaded
+const LocalService = "Local"
+
+// RecognizeText runs tesseract on the image and returns the recognised text.
+// language uses tesseract's codes, e.g. "eng" or "eng+deu".
+func RecognizeText(r Runner, imagePath string, language string) (string, error) {
+	if !haveBinaries(r, "tesseract") {
+		return "", fmt.Errorf("OCR needs tesseract")
+	}
+	if language == "" {
+		language = "eng"
+	}
+
+	output, err := r.Output("tesseract", imagePath, "stdout", "-l", language)
+	if err != nil {
+		return "", fmt.Errorf("tesseract failed (is the %q language data installed?): %w", language, err)
+	}
+
+	return strings.TrimSpace(string(output)), nil
+}
+
+// WriteTextFile stores text in a temporary .txt file for uploading
+func WriteTextFile(text string, prefix string) (string, error) {
+	tempDir, err := os.MkdirTemp("", "caplet-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp directory: %w", err)
+	}
+
+	path := filepath.Join(tempDir, fmt.Sprintf("%s-%s.txt", prefix, time.Now().Format("2006-01-02_15-04-05")))
+	if err := os.WriteFile(path, []byte(text+"\n"), 0644); err != nil {
+		return "", fmt.Errorf("failed to write text file: %w", err)
+	}
+
+	return path, nil
+}
+
+// SaveLocalCapture moves a capture into the save folder and records it in
+// history without uploading it, keeping the extra fields from record
+func SaveLocalCapture(filePath string, savePath string, organized bool, historyPath string, record Upload) (string, error) {
+	saveDir := ResolveSaveDir(savePath, organized)
+	if err := os.MkdirAll(saveDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create savePath directory: %w", err)
+	}
+
+	hash, err := HashFile(filePath)
+	if err != nil {
+		return "", err
+	}
+
+	dstFilePath := filepath.Join(saveDir, filepath.Base(filePath))
+	if err := moveFile(filePath, dstFilePath); err != nil {
+		return "", err
+	}
+
+	record.File = dstFilePath
+	record.Timestamp = time.Now().Format(time.RFC3339)
+	record.Service = LocalService
+	record.Hash = hash
+
+	if err := SaveToHistory(historyPath, record); err != nil {
+		return "", fmt.Errorf("failed to save to history: %w", err)
+	}
+
+	return dstFilePath, nil
+}
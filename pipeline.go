@@ -0,0 +1,289 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/draw"
+)
+
+// PipelineTask is one step of the after-capture pipeline.
+// Which fields are used depends on Type:
+//
+//	resize:   maxDimension or percent
+//	convert:  format (png, jpeg or webp) and optionally quality
+//	quality:  quality (1-100) for JPEG output, WebP is lossless
+//	border:   width and color
+//	shadow:   size, offset and color
+//	optimize: re-encode PNGs with the best compression
+//	watermark: image (scaled to width) or text with font, fontSize and
+//	           color, placed at position with opacity and margin
+type PipelineTask struct {
+	Type         string `json:"type"`
+	MaxDimension int    `json:"maxDimension,omitempty"`
+	Percent      int    `json:"percent,omitempty"`
+	Format       string `json:"format,omitempty"`
+	Quality      int    `json:"quality,omitempty"`
+	Width        int    `json:"width,omitempty"`
+	Size         int    `json:"size,omitempty"`
+	Offset       int    `json:"offset,omitempty"`
+	Color        string `json:"color,omitempty"`
+
+	Image    string  `json:"image,omitempty"`
+	Text     string  `json:"text,omitempty"`
+	Font     string  `json:"font,omitempty"`
+	FontSize float64 `json:"fontSize,omitempty"`
+	Position string  `json:"position,omitempty"`
+	Opacity  float64 `json:"opacity,omitempty"`
+	Margin   *int    `json:"margin,omitempty"` // nil for the default, 0 is allowed
+}
+
+// processedImage is the state handed from one pipeline task to the next
+type processedImage struct {
+	img         image.Image
+	format      string
+	quality     int
+	compression png.CompressionLevel
+	changed     bool // Whether the image has to be encoded again
+}
+
+// RunPipeline applies tasks to the image at path and writes the result next
+// to it, returning the new path (the extension changes on conversion).
+// If no task changes the image the file is left as it is, so a JPEG is
+// not recompressed by a conversion to JPEG.
+func RunPipeline(path string, tasks []PipelineTask) (string, error) {
+	if len(tasks) == 0 {
+		return path, nil
+	}
+
+	img, format, err := LoadImage(path)
+	if err != nil {
+		return "", err
+	}
+
+	// Only PNG, JPEG and WebP are written back, other formats become PNG
+	state := &processedImage{img: img, format: format, quality: 90, compression: png.DefaultCompression}
+	if format != "png" && format != "jpeg" && format != "webp" {
+		state.format = "png"
+		state.changed = true
+	}
+
+	for i, task := range tasks {
+		if err := applyTask(state, task); err != nil {
+			return "", fmt.Errorf("pipeline task %d (%s): %w", i+1, task.Type, err)
+		}
+	}
+
+	if !state.changed {
+		return path, nil
+	}
+
+	outputPath := strings.TrimSuffix(path, filepath.Ext(path)) + imageExtension(state.format)
+	if err := writeProcessedImage(state, outputPath); err != nil {
+		return "", err
+	}
+
+	return outputPath, nil
+}
+
+func applyTask(state *processedImage, task PipelineTask) error {
+	switch strings.ToLower(task.Type) {
+	case "resize":
+		bounds := state.img.Bounds()
+		switch {
+		case task.MaxDimension > 0:
+			state.img = ScaleToFit(state.img, task.MaxDimension)
+		case task.Percent > 0:
+			width := max(1, bounds.Dx()*task.Percent/100)
+			height := max(1, bounds.Dy()*task.Percent/100)
+			state.img = ScaleImage(state.img, width, height)
+		default:
+			return fmt.Errorf("needs maxDimension or percent")
+		}
+
+	case "convert":
+		format := strings.ToLower(task.Format)
+		if format == "jpg" {
+			format = "jpeg"
+		}
+		if format != "png" && format != "jpeg" && format != "webp" {
+			return fmt.Errorf("unsupported format %q (png, jpeg or webp)", task.Format)
+		}
+		if format == state.format && task.Quality <= 0 {
+			// Already in that format, re-encoding would only lose quality
+			return nil
+		}
+		state.format = format
+		if task.Quality > 0 {
+			state.quality = task.Quality
+		}
+
+	case "quality":
+		if task.Quality < 1 || task.Quality > 100 {
+			return fmt.Errorf("quality must be between 1 and 100")
+		}
+		state.quality = task.Quality
+
+	case "border":
+		if task.Width <= 0 {
+			return fmt.Errorf("needs a width")
+		}
+		c, err := parseHexColor(task.Color, color.Black)
+		if err != nil {
+			return err
+		}
+		state.img = addBorder(state.img, task.Width, c)
+
+	case "shadow":
+		size := task.Size
+		if size <= 0 {
+			size = 16
+		}
+		c, err := parseHexColor(task.Color, color.NRGBA{A: 128})
+		if err != nil {
+			return err
+		}
+		state.img = addShadow(state.img, size, task.Offset, c)
+
+	case "optimize", "optimise":
+		state.compression = png.BestCompression
+
+	case "watermark":
+		watermarked, err := addWatermark(state.img, task)
+		if err != nil {
+			return err
+		}
+		state.img = watermarked
+
+	default:
+		return fmt.Errorf("unknown task type")
+	}
+
+	state.changed = true
+	return nil
+}
+
+// writeProcessedImage encodes the pipeline result
+func writeProcessedImage(state *processedImage, outputPath string) error {
+	file, err := os.Create(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to create processed image: %w", err)
+	}
+
+	if err := EncodeImage(file, state.img, state.format, state.quality, state.compression); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to encode processed image: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write processed image: %w", err)
+	}
+
+	return nil
+}
+
+// imageExtension returns the file extension used for an image format
+func imageExtension(format string) string {
+	if format == "jpeg" {
+		return ".jpg"
+	}
+	return "." + format
+}
+
+// parseHexColor parses "#rrggbb" or "#rrggbbaa", returning fallback for ""
+func parseHexColor(value string, fallback color.Color) (color.Color, error) {
+	if value == "" {
+		return fallback, nil
+	}
+
+	hex := strings.TrimPrefix(value, "#")
+	if len(hex) != 6 && len(hex) != 8 {
+		return nil, fmt.Errorf("invalid color %q, use #rrggbb or #rrggbbaa", value)
+	}
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+
+	n, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid color %q, use #rrggbb or #rrggbbaa", value)
+	}
+
+	return color.NRGBA{R: uint8(n >> 24), G: uint8(n >> 16), B: uint8(n >> 8), A: uint8(n)}, nil
+}
+
+// addBorder surrounds img with a solid border of the given width
+func addBorder(img image.Image, width int, c color.Color) image.Image {
+	bounds := img.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, bounds.Dx()+2*width, bounds.Dy()+2*width))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(c), image.Point{}, draw.Src)
+	draw.Draw(dst, image.Rect(width, width, width+bounds.Dx(), width+bounds.Dy()), img, bounds.Min, draw.Over)
+	return dst
+}
+
+// addShadow places img on a transparent canvas above a blurred drop shadow
+// that is shifted down and to the right by offset
+func addShadow(img image.Image, size int, offset int, c color.Color) image.Image {
+	bounds := img.Bounds()
+	width := bounds.Dx() + 2*size + offset
+	height := bounds.Dy() + 2*size + offset
+
+	// Draw the shadow's shape as an alpha mask, then blur it
+	mask := image.NewAlpha(image.Rect(0, 0, width, height))
+	shape := image.Rect(size+offset, size+offset, size+offset+bounds.Dx(), size+offset+bounds.Dy())
+	draw.Draw(mask, shape, image.Opaque, image.Point{}, draw.Src)
+	// Three box blurs approximate a gaussian
+	for range 3 {
+		boxBlurAlpha(mask, size/2)
+	}
+
+	dst := image.NewRGBA(mask.Bounds())
+	draw.DrawMask(dst, dst.Bounds(), image.NewUniform(c), image.Point{}, mask, image.Point{}, draw.Over)
+	draw.Draw(dst, image.Rect(size, size, size+bounds.Dx(), size+bounds.Dy()), img, bounds.Min, draw.Over)
+	return dst
+}
+
+// boxBlurAlpha blurs an alpha mask in place with a box of the given radius
+func boxBlurAlpha(mask *image.Alpha, radius int) {
+	if radius <= 0 {
+		return
+	}
+
+	width, height := mask.Rect.Dx(), mask.Rect.Dy()
+	buf := make([]uint8, max(width, height))
+	window := 2*radius + 1
+
+	blurLine := func(get func(int) uint8, set func(int, uint8), n int) {
+		sum := 0
+		for i := -radius; i <= radius; i++ {
+			if i >= 0 && i < n {
+				sum += int(get(i))
+			}
+		}
+		for i := range n {
+			buf[i] = uint8(sum / window)
+			if out := i - radius; out >= 0 {
+				sum -= int(get(out))
+			}
+			if in := i + radius + 1; in < n {
+				sum += int(get(in))
+			}
+		}
+		for i := range n {
+			set(i, buf[i])
+		}
+	}
+
+	for y := range height {
+		row := mask.Pix[y*mask.Stride:]
+		blurLine(func(i int) uint8 { return row[i] }, func(i int, v uint8) { row[i] = v }, width)
+	}
+	for x := range width {
+		blurLine(func(i int) uint8 { return mask.Pix[i*mask.Stride+x] }, func(i int, v uint8) { mask.Pix[i*mask.Stride+x] = v }, height)
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTestImage writes a small image in format to dir and returns its path
+func writeTestImage(t *testing.T, dir string, name string, format string) string {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 32, 16))
+	for x := range 32 {
+		img.Set(x, x%16, color.RGBA{R: 200, A: 255})
+	}
+
+	var buf bytes.Buffer
+	var err error
+	if format == "jpeg" {
+		err = jpeg.Encode(&buf, img, nil)
+	} else {
+		err = png.Encode(&buf, img)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRunPipelineKeepsUnchangedImage(t *testing.T) {
+	path := writeTestImage(t, t.TempDir(), "shot.jpg", "jpeg")
+	before, _ := os.ReadFile(path)
+
+	processed, err := RunPipeline(path, []PipelineTask{{Type: "convert", Format: "jpg"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if processed != path {
+		t.Errorf("got %s, want the input %s", processed, path)
+	}
+	if after, _ := os.ReadFile(path); !bytes.Equal(before, after) {
+		t.Error("JPEG was re-encoded by a conversion to JPEG")
+	}
+}
+
+func TestRunPipelineConvert(t *testing.T) {
+	path := writeTestImage(t, t.TempDir(), "shot.png", "png")
+
+	processed, err := RunPipeline(path, []PipelineTask{{Type: "convert", Format: "jpeg", Quality: 80}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Ext(processed) != ".jpg" {
+		t.Fatalf("got %s, want a .jpg file", processed)
+	}
+	if _, format, err := LoadImage(processed); err != nil || format != "jpeg" {
+		t.Errorf("got format %q (%v), want jpeg", format, err)
+	}
+}
+
+func TestRunPipelineResizesSameFormat(t *testing.T) {
+	path := writeTestImage(t, t.TempDir(), "shot.png", "png")
+
+	processed, err := RunPipeline(path, []PipelineTask{{Type: "resize", Percent: 50}, {Type: "convert", Format: "png"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, _, err := LoadImage(processed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := img.Bounds().Size(); got != image.Pt(16, 8) {
+		t.Errorf("got size %v, want 16x8", got)
+	}
+}
+
+func TestRunPipelineConvertWebP(t *testing.T) {
+	path := writeTestImage(t, t.TempDir(), "shot.png", "png")
+	original, _, err := LoadImage(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	processed, err := RunPipeline(path, []PipelineTask{{Type: "convert", Format: "webp"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Ext(processed) != ".webp" {
+		t.Fatalf("got %s, want a .webp file", processed)
+	}
+	img, format, err := LoadImage(processed)
+	if err != nil || format != "webp" {
+		t.Fatalf("got format %q (%v), want webp", format, err)
+	}
+
+	// WebP output is lossless
+	for y := range 16 {
+		for x := range 32 {
+			got := color.NRGBAModel.Convert(img.At(x, y))
+			if want := color.NRGBAModel.Convert(original.At(x, y)); got != want {
+				t.Fatalf("pixel %d,%d is %v, want %v", x, y, got, want)
+			}
+		}
+	}
+
+	if _, err := RunPipeline(path, []PipelineTask{{Type: "convert", Format: "avif"}}); err == nil || !strings.Contains(err.Error(), "unsupported format") {
+		t.Errorf("got error %v, want unsupported format", err)
+	}
+}
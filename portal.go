@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	portalBusName       = "org.freedesktop.portal.Desktop"
+	portalObjectPath    = "/org/freedesktop/portal/desktop"
+	portalScreenshot    = "org.freedesktop.portal.Screenshot"
+	portalRequest       = "org.freedesktop.portal.Request"
+	portalRequestPrefix = "/org/freedesktop/portal/desktop/request/"
+)
+
+// portalTimeout bounds the wait for the portal's response. It is generous
+// because interactive requests wait for the user to pick a region.
+var portalTimeout = 5 * time.Minute
+
+// Response codes of org.freedesktop.portal.Request.Response
+const (
+	portalResponseSuccess   = 0
+	portalResponseCancelled = 1
+)
+
+func init() {
+	RegisterBackend(portalBackend{})
+}
+
+// portalBackend takes screenshots through xdg-desktop-portal over D-Bus
+type portalBackend struct {
+	// Address of the bus to talk to, empty for the session bus.
+	// Lets the backend run against a stand-in portal service.
+	Address string
+}
+
+func (portalBackend) Name() string       { return "portal" }
+func (portalBackend) Sessions() []string { return []string{SessionWayland, SessionX11} }
+
+func (b portalBackend) connect() (*dbus.Conn, error) {
+	if b.Address != "" {
+		conn, err := dbus.Connect(b.Address)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to %s: %w", b.Address, err)
+		}
+		return conn, nil
+	}
+
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to session bus: %w", err)
+	}
+	return conn, nil
+}
+
+// Available checks that a portal implementing Screenshot is reachable
+func (b portalBackend) Available(r Runner) bool {
+	conn, err := b.connect()
+	if err != nil {
+		return false
+	}
+	defer conn.Close()
+
+	obj := conn.Object(portalBusName, portalObjectPath)
+	_, err = obj.GetProperty(portalScreenshot + ".version")
+	return err == nil
+}
+
+// Capture asks the portal for a screenshot and moves the file it returns
+// into req.OutputPath. A cancelled request leaves OutputPath unwritten.
+func (b portalBackend) Capture(r Runner, req CaptureRequest) error {
+	if req.Cursor && !req.Region {
+		// Only the interactive dialog lets the user choose
+		fmt.Fprintln(os.Stderr, "The screenshot portal cannot be asked to include the mouse cursor")
+	}
+
+	conn, err := b.connect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// Subscribe before calling so the Response signal cannot be missed
+	token := fmt.Sprintf("caplet%d", rand.Uint32())
+	sender := strings.ReplaceAll(strings.TrimPrefix(conn.Names()[0], ":"), ".", "_")
+	requestPath := dbus.ObjectPath(portalRequestPrefix + sender + "/" + token)
+
+	signals := make(chan *dbus.Signal, 4)
+	conn.Signal(signals)
+	if err := watchPortalRequest(conn, requestPath); err != nil {
+		return err
+	}
+
+	options := map[string]dbus.Variant{
+		"handle_token": dbus.MakeVariant(token),
+		"interactive":  dbus.MakeVariant(req.Region),
+		"modal":        dbus.MakeVariant(true),
+	}
+
+	var handle dbus.ObjectPath
+	obj := conn.Object(portalBusName, portalObjectPath)
+	if err := obj.Call(portalScreenshot+".Screenshot", 0, "", options).Store(&handle); err != nil {
+		return fmt.Errorf("portal screenshot request failed: %w", err)
+	}
+
+	// Old portal versions ignore handle_token and pick their own path
+	if handle != requestPath {
+		if err := watchPortalRequest(conn, handle); err != nil {
+			return err
+		}
+	}
+
+	timeout := time.After(portalTimeout)
+	for {
+		var signal *dbus.Signal
+		select {
+		case signal = <-signals:
+		case <-timeout:
+			return fmt.Errorf("the portal did not respond within %s", portalTimeout)
+		}
+		if signal == nil {
+			return fmt.Errorf("lost connection to the portal before it responded")
+		}
+		if signal.Path != handle || signal.Name != portalRequest+".Response" {
+			continue
+		}
+
+		var response uint32
+		var results map[string]dbus.Variant
+		if err := dbus.Store(signal.Body, &response, &results); err != nil {
+			return fmt.Errorf("unexpected portal response: %w", err)
+		}
+
+		switch response {
+		case portalResponseSuccess:
+		case portalResponseCancelled:
+			// Leave outputPath missing, callers treat that as cancellation
+			return nil
+		default:
+			return fmt.Errorf("portal screenshot failed with response %d", response)
+		}
+
+		uri, ok := results["uri"].Value().(string)
+		if !ok {
+			return fmt.Errorf("portal response contains no file uri")
+		}
+
+		fileURL, err := url.Parse(uri)
+		if err != nil || fileURL.Scheme != "file" {
+			return fmt.Errorf("unsupported screenshot uri from portal: %s", uri)
+		}
+
+		return moveFile(fileURL.Path, req.OutputPath)
+	}
+}
+
+func watchPortalRequest(conn *dbus.Conn, path dbus.ObjectPath) error {
+	err := conn.AddMatchSignal(
+		dbus.WithMatchObjectPath(path),
+		dbus.WithMatchInterface(portalRequest),
+		dbus.WithMatchMember("Response"),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to watch portal request: %w", err)
+	}
+	return nil
+}
+
+// moveFile moves src to dst, copying when they are on different filesystems
+func moveFile(src string, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	srcFile, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", src, err)
+	}
+	defer srcFile.Close()
+
+	dstFile, err := os.Create(dst)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", dst, err)
+	}
+	defer dstFile.Close()
+
+	if _, err := io.Copy(dstFile, srcFile); err != nil {
+		return fmt.Errorf("failed to copy %s: %w", src, err)
+	}
+
+	return os.Remove(src)
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// fakePortal stands in for xdg-desktop-portal's Screenshot interface and
+// answers every request with response, pointing at file on success
+type fakePortal struct {
+	conn     *dbus.Conn
+	response uint32
+	file     string
+	silent   bool // Never send a Response
+}
+
+func (p *fakePortal) Screenshot(sender dbus.Sender, parent string, options map[string]dbus.Variant) (dbus.ObjectPath, *dbus.Error) {
+	token, _ := options["handle_token"].Value().(string)
+	caller := strings.ReplaceAll(strings.TrimPrefix(string(sender), ":"), ".", "_")
+	handle := dbus.ObjectPath(portalRequestPrefix + caller + "/" + token)
+
+	if !p.silent {
+		results := map[string]dbus.Variant{}
+		if p.response == portalResponseSuccess {
+			results["uri"] = dbus.MakeVariant("file://" + p.file)
+		}
+		// The real portal answers after the call returned the handle
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			p.conn.Emit(handle, portalRequest+".Response", p.response, results)
+		}()
+	}
+
+	return handle, nil
+}
+
+// startPrivateBus runs a dbus-daemon for the test and returns its address
+func startPrivateBus(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("dbus-daemon"); err != nil {
+		t.Skip("dbus-daemon is not installed")
+	}
+
+	dir := t.TempDir()
+	config := filepath.Join(dir, "bus.conf")
+	err := os.WriteFile(config, []byte(`<busconfig>
+  <type>session</type>
+  <listen>unix:path=`+filepath.Join(dir, "bus")+`</listen>
+  <auth>EXTERNAL</auth>
+  <policy context="default">
+    <allow send_destination="*" eavesdrop="true"/>
+    <allow eavesdrop="true"/>
+    <allow own="*"/>
+  </policy>
+</busconfig>
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command("dbus-daemon", "--config-file="+config, "--nofork", "--print-address")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+
+	address, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		t.Fatalf("dbus-daemon did not print its address: %v", err)
+	}
+	return strings.TrimSpace(address)
+}
+
+// exportFakePortal claims the portal's bus name on the bus at address
+func exportFakePortal(t *testing.T, address string, portal *fakePortal) {
+	t.Helper()
+	conn, err := dbus.Connect(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	portal.conn = conn
+
+	if err := conn.Export(portal, portalObjectPath, portalScreenshot); err != nil {
+		t.Fatal(err)
+	}
+	reply, err := conn.RequestName(portalBusName, dbus.NameFlagDoNotQueue)
+	if err != nil || reply != dbus.RequestNameReplyPrimaryOwner {
+		t.Fatalf("failed to own %s: %v", portalBusName, err)
+	}
+}
+
+func TestPortalCapture(t *testing.T) {
+	address := startPrivateBus(t)
+
+	shot := filepath.Join(t.TempDir(), "portal-shot.png")
+	if err := os.WriteFile(shot, []byte("png data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	exportFakePortal(t, address, &fakePortal{response: portalResponseSuccess, file: shot})
+
+	output := filepath.Join(t.TempDir(), "screenshot.png")
+	if err := (portalBackend{Address: address}).Capture(nil, CaptureRequest{OutputPath: output}); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatalf("capture was not moved to the output path: %v", err)
+	}
+	if string(data) != "png data" {
+		t.Errorf("output contains %q, want the portal's file", data)
+	}
+	if FileExists(shot) {
+		t.Errorf("portal file %s was copied instead of moved", shot)
+	}
+}
+
+func TestPortalCaptureCancelled(t *testing.T) {
+	address := startPrivateBus(t)
+	exportFakePortal(t, address, &fakePortal{response: portalResponseCancelled})
+
+	output := filepath.Join(t.TempDir(), "screenshot.png")
+	if err := (portalBackend{Address: address}).Capture(nil, CaptureRequest{Region: true, OutputPath: output}); err != nil {
+		t.Fatal(err)
+	}
+	if FileExists(output) {
+		t.Errorf("cancelled capture left %s behind", output)
+	}
+}
+
+func TestPortalCaptureTimeout(t *testing.T) {
+	address := startPrivateBus(t)
+	exportFakePortal(t, address, &fakePortal{silent: true})
+
+	timeout := portalTimeout
+	portalTimeout = 200 * time.Millisecond
+	t.Cleanup(func() { portalTimeout = timeout })
+
+	output := filepath.Join(t.TempDir(), "screenshot.png")
+	err := (portalBackend{Address: address}).Capture(nil, CaptureRequest{OutputPath: output})
+	if err == nil || !strings.Contains(err.Error(), "did not respond") {
+		t.Errorf("got error %v, want a timeout", err)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// qrImageSize is the width and height of generated QR code PNGs
+const qrImageSize = 320
+
+// WriteQRCode encodes content as a QR code PNG in a temporary file
+func WriteQRCode(content string) (string, error) {
+	tempDir, err := os.MkdirTemp("", "caplet-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp directory: %w", err)
+	}
+
+	path := filepath.Join(tempDir, fmt.Sprintf("qr-%s.png", time.Now().Format("2006-01-02_15-04-05")))
+	if err := qrcode.WriteFile(content, qrcode.Medium, qrImageSize, path); err != nil {
+		return "", fmt.Errorf("failed to encode QR code: %w", err)
+	}
+
+	return path, nil
+}
+
+// TerminalQRCode renders content as a QR code using half-block characters,
+// two modules per character cell. Light modules are drawn as blocks so the
+// code scans on the usual dark terminal background.
+func TerminalQRCode(content string) (string, error) {
+	code, err := qrcode.New(content, qrcode.Medium)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode QR code: %w", err)
+	}
+
+	bitmap := code.Bitmap()
+	var out strings.Builder
+	for y := 0; y < len(bitmap); y += 2 {
+		for x := range bitmap[y] {
+			top := !bitmap[y][x]
+			bottom := y+1 < len(bitmap) && !bitmap[y+1][x]
+			switch {
+			case top && bottom:
+				out.WriteString("█")
+			case top:
+				out.WriteString("▀")
+			case bottom:
+				out.WriteString("▄")
+			default:
+				out.WriteString(" ")
+			}
+		}
+		out.WriteString("\n")
+	}
+
+	return out.String(), nil
+}
+
+// isTerminal reports whether f is connected to a terminal
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// StopRecording asks a running "caplet -mode record" to finish.
+// It reports whether a recording was running.
+func StopRecording() (bool, error) {
+	return SignalInstance("record", syscall.SIGINT)
+}
+
+// RecordScreen records the screen (or a selected region) until the process
+// receives SIGINT/SIGTERM, usually sent by a second "caplet -mode record".
+// It returns the path of the finished video, converted to GIF if configured.
+func RecordScreen(region bool, config RecordingConfig, showNotification bool) (string, error) {
+	format := strings.ToLower(config.Format)
+	if format == "" {
+		format = "mp4"
+	}
+	if format != "mp4" && format != "webm" && format != "gif" {
+		return "", fmt.Errorf("unsupported recording format %q (mp4, webm or gif)", config.Format)
+	}
+
+	tempDir, err := os.MkdirTemp("", "caplet-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp directory: %w", err)
+	}
+
+	// GIFs are recorded as MP4 first and converted afterwards
+	videoFormat := format
+	if format == "gif" {
+		videoFormat = "mp4"
+	}
+	videoPath := filepath.Join(tempDir, fmt.Sprintf("recording-%s.%s", time.Now().Format("2006-01-02_15-04-05"), videoFormat))
+
+	var recorder *exec.Cmd
+	if CurrentSession() == SessionWayland {
+		recorder, err = startWfRecorder(defaultRunner, region, videoPath, videoFormat, config)
+	} else {
+		recorder, err = startX11Recorder(defaultRunner, region, videoPath, videoFormat, config)
+	}
+	if err != nil {
+		return "", err
+	}
+	if recorder == nil {
+		// Region selection was cancelled
+		return videoPath, nil
+	}
+
+	removePidFile, err := WritePidFile("record")
+	if err != nil {
+		recorder.Process.Signal(os.Interrupt)
+		recorder.Wait()
+		return "", err
+	}
+	defer removePidFile()
+
+	fmt.Println("Recording... run 'caplet -mode record' again or press Ctrl+C to stop.")
+	if showNotification {
+		NOTIFY_ID, err = Notify("Recording... run caplet -mode record again to stop", NOTIFY_ID, "")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to show notification: %v\n", err)
+		}
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(stop)
+
+	exited := make(chan error, 1)
+	go func() { exited <- recorder.Wait() }()
+
+	select {
+	case <-stop:
+		// Both wf-recorder and ffmpeg finish the file cleanly on SIGINT
+		recorder.Process.Signal(os.Interrupt)
+		<-exited
+	case err := <-exited:
+		if err != nil {
+			return "", fmt.Errorf("recorder stopped unexpectedly: %w", err)
+		}
+	}
+
+	fmt.Println("Recording stopped.")
+	go PlayCaptured()
+
+	if !FileExists(videoPath) {
+		return "", fmt.Errorf("recorder did not write %s", videoPath)
+	}
+
+	if format != "gif" {
+		return videoPath, nil
+	}
+
+	if showNotification {
+		NOTIFY_ID, _ = Notify("Converting recording to GIF...", NOTIFY_ID, "")
+	}
+	gifPath := strings.TrimSuffix(videoPath, filepath.Ext(videoPath)) + ".gif"
+	if err := ConvertToGIF(defaultRunner, videoPath, gifPath, config.GifFPS, config.GifWidth); err != nil {
+		return "", err
+	}
+	os.Remove(videoPath)
+
+	return gifPath, nil
+}
+
+// startWfRecorder starts wf-recorder on wlroots compositors.
+// It returns a nil command if the region selection was cancelled.
+func startWfRecorder(r Runner, region bool, outputPath string, format string, config RecordingConfig) (*exec.Cmd, error) {
+	if !haveBinaries(r, "wf-recorder") {
+		return nil, fmt.Errorf("recording on Wayland needs wf-recorder (wlroots compositors only)")
+	}
+
+	var geometry string
+	if region {
+		area, selected, err := SelectArea(r)
+		if err != nil || !selected {
+			return nil, err
+		}
+		geometry = area.GrimGeometry()
+	}
+
+	recorder, err := r.Start("wf-recorder", wfRecorderArgs(outputPath, format, geometry, config)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start wf-recorder: %w", err)
+	}
+	return recorder, nil
+}
+
+// wfRecorderArgs builds the wf-recorder command line, geometry is slurp's "X,Y WxH"
+func wfRecorderArgs(outputPath string, format string, geometry string, config RecordingConfig) []string {
+	args := []string{"-y", "-f", outputPath}
+	if geometry != "" {
+		args = append(args, "-g", geometry)
+	}
+	if format == "webm" {
+		args = append(args, "-c", "libvpx-vp9")
+	}
+	if config.FPS > 0 {
+		args = append(args, "-r", strconv.Itoa(config.FPS))
+	}
+	if config.Audio {
+		args = append(args, "--audio")
+	}
+	return args
+}
+
+// startX11Recorder starts ffmpeg's x11grab.
+// It returns a nil command if the region selection was cancelled.
+func startX11Recorder(r Runner, region bool, outputPath string, format string, config RecordingConfig) (*exec.Cmd, error) {
+	if !haveBinaries(r, "ffmpeg") {
+		return nil, fmt.Errorf("recording on X11 needs ffmpeg")
+	}
+
+	var area *Rect
+	if region {
+		selectedArea, selected, err := SelectArea(r)
+		if err != nil || !selected {
+			return nil, err
+		}
+		area = &selectedArea
+	}
+
+	display := os.Getenv("DISPLAY")
+	if display == "" {
+		display = ":0"
+	}
+
+	recorder, err := r.Start("ffmpeg", x11RecorderArgs(outputPath, format, display, area, config)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start ffmpeg: %w", err)
+	}
+	return recorder, nil
+}
+
+// x11RecorderArgs builds the ffmpeg x11grab command line, a nil area records the whole screen
+func x11RecorderArgs(outputPath string, format string, display string, area *Rect, config RecordingConfig) []string {
+	fps := config.FPS
+	if fps <= 0 {
+		fps = 30
+	}
+
+	args := []string{"-y", "-loglevel", "error", "-f", "x11grab", "-framerate", strconv.Itoa(fps)}
+	input := display
+	if area != nil {
+		// Most encoders need even dimensions
+		args = append(args, "-video_size", fmt.Sprintf("%dx%d", area.W&^1, area.H&^1))
+		input = fmt.Sprintf("%s+%d,%d", display, area.X, area.Y)
+	}
+	args = append(args, "-i", input)
+
+	if config.Audio {
+		args = append(args, "-f", "pulse", "-i", "default")
+	}
+
+	if format == "webm" {
+		args = append(args, "-c:v", "libvpx-vp9", "-crf", "35", "-b:v", "0")
+		if config.Audio {
+			args = append(args, "-c:a", "libopus")
+		}
+	} else {
+		args = append(args, "-c:v", "libx264", "-preset", "veryfast", "-pix_fmt", "yuv420p")
+		if config.Audio {
+			args = append(args, "-c:a", "aac")
+		}
+	}
+
+	return append(args, outputPath)
+}
+
+// ConvertToGIF converts a video to an optimised GIF using a generated palette
+func ConvertToGIF(r Runner, videoPath string, gifPath string, fps int, width int) error {
+	if !haveBinaries(r, "ffmpeg") {
+		return fmt.Errorf("GIF conversion needs ffmpeg")
+	}
+
+	if fps <= 0 {
+		fps = 15
+	}
+	scale := "iw"
+	if width > 0 {
+		scale = strconv.Itoa(width)
+	}
+
+	filter := fmt.Sprintf("fps=%d,scale=%s:-1:flags=lanczos,split[a][b];[a]palettegen=stats_mode=diff[p];[b][p]paletteuse=dither=bayer:bayer_scale=5", fps, scale)
+	if err := r.Run("ffmpeg", "-y", "-loglevel", "error", "-i", videoPath, "-vf", filter, "-loop", "0", gifPath); err != nil {
+		return fmt.Errorf("GIF conversion failed: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/image/draw"
+)
+
+// RectList collects repeated -redact WxH+X+Y flags
+type RectList []Rect
+
+func (l *RectList) String() string {
+	var parts []string
+	for _, r := range *l {
+		parts = append(parts, r.X11Geometry())
+	}
+	return strings.Join(parts, ",")
+}
+
+func (l *RectList) Set(value string) error {
+	r, err := ParseX11Geometry(value)
+	if err != nil {
+		return err
+	}
+	*l = append(*l, r)
+	return nil
+}
+
+// SelectRedactions lets the user select areas to redact one after another
+// until the selection is cancelled (Esc). origin is the screen position of
+// the captured image and scale its pixels per screen unit.
+func SelectRedactions(r Runner, origin image.Point, scale float64, showNotification bool) ([]Rect, error) {
+	fmt.Println("Select areas to redact, press Esc when done.")
+	if showNotification {
+		var err error
+		NOTIFY_ID, err = Notify("Select areas to redact, press Esc when done", NOTIFY_ID, "")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to show notification: %v\n", err)
+		}
+	}
+
+	var areas []Rect
+	for {
+		area, selected, err := SelectArea(r)
+		if err != nil {
+			return nil, err
+		}
+		if !selected {
+			return areas, nil
+		}
+		areas = append(areas, scaleRect(area, origin, scale))
+	}
+}
+
+// RedactImageFile covers areas of the image at path, given in image pixels,
+// and writes the result back as PNG. style is "pixelate" or "black".
+func RedactImageFile(path string, areas []Rect, style string) error {
+	img, _, err := LoadImage(path)
+	if err != nil {
+		return err
+	}
+
+	bounds := img.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, bounds.Min, draw.Src)
+
+	for _, area := range areas {
+		rect := image.Rect(area.X, area.Y, area.X+area.W, area.Y+area.H).Intersect(dst.Bounds())
+		if rect.Empty() {
+			return fmt.Errorf("redaction %s lies outside the %dx%d image", area.X11Geometry(), dst.Bounds().Dx(), dst.Bounds().Dy())
+		}
+
+		switch style {
+		case "", "pixelate":
+			pixelate(dst, rect)
+		case "black":
+			draw.Draw(dst, rect, image.Black, image.Point{}, draw.Src)
+		default:
+			return fmt.Errorf("unknown redaction style %q (pixelate or black)", style)
+		}
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create redacted image: %w", err)
+	}
+	defer file.Close()
+
+	if err := png.Encode(file, dst); err != nil {
+		return fmt.Errorf("failed to encode redacted image: %w", err)
+	}
+
+	return nil
+}
+
+// pixelate replaces rect with coarse blocks of its average colour.
+// Blocks are large enough that text underneath can't be recovered.
+func pixelate(img *image.RGBA, rect image.Rectangle) {
+	block := max(16, min(rect.Dx(), rect.Dy())/4)
+
+	for y := rect.Min.Y; y < rect.Max.Y; y += block {
+		for x := rect.Min.X; x < rect.Max.X; x += block {
+			cell := image.Rect(x, y, x+block, y+block).Intersect(rect)
+
+			var r, g, b, a, n uint64
+			for cy := cell.Min.Y; cy < cell.Max.Y; cy++ {
+				for cx := cell.Min.X; cx < cell.Max.X; cx++ {
+					c := img.RGBAAt(cx, cy)
+					r += uint64(c.R)
+					g += uint64(c.G)
+					b += uint64(c.B)
+					a += uint64(c.A)
+					n++
+				}
+			}
+
+			average := color.RGBA{R: uint8(r / n), G: uint8(g / n), B: uint8(b / n), A: uint8(a / n)}
+			draw.Draw(img, cell, image.NewUniform(average), image.Point{}, draw.Src)
+		}
+	}
+}
+
+// SaveOriginal copies the unredacted capture into the save folder as
+// "<name>-original.png" so it is kept next to the redacted copy
+func SaveOriginal(filePath string, savePath string, organized bool) (string, error) {
+	saveDir := ResolveSaveDir(savePath, organized)
+	if err := os.MkdirAll(saveDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create savePath directory: %w", err)
+	}
+
+	ext := filepath.Ext(filePath)
+	dstPath := filepath.Join(saveDir, strings.TrimSuffix(filepath.Base(filePath), ext)+"-original"+ext)
+
+	src, err := os.Open(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open original: %w", err)
+	}
+	defer src.Close()
+
+	dst, err := os.Create(dstPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to create original copy: %w", err)
+	}
+	defer dst.Close()
+
+	if _, err := io.Copy(dst, src); err != nil {
+		return "", fmt.Errorf("failed to copy original: %w", err)
+	}
+
+	return dstPath, nil
+}
+
+// captureOrigin returns where the captured image sits on screen and its
+// pixels per screen unit, for mapping selected areas into the image.
+// Captures without a known area are fullscreen and start at 0,0.
+func captureOrigin(path string, area *Rect) (image.Point, float64) {
+	if area == nil {
+		return image.Point{}, 1
+	}
+
+	scale := 1.0
+	if file, err := os.Open(path); err == nil {
+		defer file.Close()
+		if config, _, err := image.DecodeConfig(file); err == nil && area.W > 0 {
+			scale = float64(config.Width) / float64(area.W)
+		}
+	}
+
+	return image.Pt(area.X, area.Y), scale
+}
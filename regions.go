@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// lastRegionPath is where the last selected region is remembered
+func lastRegionPath() string {
+	return filepath.Join(os.Getenv("HOME"), ".config", "caplet", "last-region")
+}
+
+// SaveLastRegion remembers area for -mode last-region
+func SaveLastRegion(area Rect) error {
+	path := lastRegionPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(area.X11Geometry()+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to save last region: %w", err)
+	}
+	return nil
+}
+
+// rememberRegion saves a region the user just selected, a failure only
+// costs -mode last-region so it is reported and otherwise ignored
+func rememberRegion(area Rect) {
+	if err := SaveLastRegion(area); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to remember region: %v\n", err)
+	}
+}
+
+// LoadLastRegion returns the region selected last
+func LoadLastRegion() (Rect, error) {
+	data, err := os.ReadFile(lastRegionPath())
+	if os.IsNotExist(err) {
+		return Rect{}, fmt.Errorf("no region selected yet, take a region screenshot first")
+	}
+	if err != nil {
+		return Rect{}, fmt.Errorf("failed to read last region: %w", err)
+	}
+	return ParseX11Geometry(string(data))
+}
+
+// ResolveRegion turns a -geometry value, either WxH+X+Y or the name of a
+// region in the config, into a screen area
+func ResolveRegion(config Config, spec string) (Rect, error) {
+	if geometry, found := config.Regions[spec]; found {
+		area, err := ParseX11Geometry(geometry)
+		if err != nil {
+			return Rect{}, fmt.Errorf("region %q: %w", spec, err)
+		}
+		return area, nil
+	}
+
+	area, err := ParseX11Geometry(spec)
+	if err != nil {
+		if len(config.Regions) == 0 {
+			return Rect{}, err
+		}
+		names := slices.Sorted(maps.Keys(config.Regions))
+		return Rect{}, fmt.Errorf("%w or one of the configured regions: %s", err, strings.Join(names, ", "))
+	}
+	return area, nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestResolveRegion(t *testing.T) {
+	config := Config{Regions: map[string]string{
+		"dashboard": "1280x720+0+360",
+		"broken":    "wide",
+	}}
+
+	tests := []struct {
+		spec    string
+		want    Rect
+		wantErr string
+	}{
+		{"800x600+100+50", Rect{X: 100, Y: 50, W: 800, H: 600}, ""},
+		{"200x100+-1920+0", Rect{X: -1920, Y: 0, W: 200, H: 100}, ""},
+		{"dashboard", Rect{X: 0, Y: 360, W: 1280, H: 720}, ""},
+		{"broken", Rect{}, `region "broken"`},
+		{"0x600+0+0", Rect{}, "invalid geometry"},
+		{"nowhere", Rect{}, "configured regions: broken, dashboard"},
+	}
+
+	for _, tt := range tests {
+		area, err := ResolveRegion(config, tt.spec)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: got error %v, want it to contain %q", tt.spec, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.spec, err)
+			continue
+		}
+		if area != tt.want {
+			t.Errorf("%s: got %+v, want %+v", tt.spec, area, tt.want)
+		}
+	}
+
+	// Without configured regions only the geometry error is reported
+	if _, err := ResolveRegion(Config{}, "nowhere"); err == nil || strings.Contains(err.Error(), "configured regions") {
+		t.Errorf("got error %v, want a plain geometry error", err)
+	}
+}
+
+func TestLastRegionRoundTrip(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	if _, err := LoadLastRegion(); err == nil || !strings.Contains(err.Error(), "no region selected yet") {
+		t.Errorf("got error %v before saving, want no region selected yet", err)
+	}
+
+	area := Rect{X: -200, Y: 40, W: 640, H: 480}
+	if err := SaveLastRegion(area); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(lastRegionPath()); err != nil {
+		t.Fatalf("last region was not written: %v", err)
+	}
+
+	loaded, err := LoadLastRegion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded != area {
+		t.Errorf("got %+v, want %+v", loaded, area)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+)
+
+// Runner starts external programs. Screenshot backends go through a Runner
+// so the commands they build can be checked against fake binaries.
+type Runner interface {
+	// LookPath resolves the executable for name
+	LookPath(name string) (string, error)
+	// Run runs the program and waits for it to finish
+	Run(name string, args ...string) error
+	// Output runs the program and returns its standard output
+	Output(name string, args ...string) ([]byte, error)
+	// Start starts the program without waiting for it
+	Start(name string, args ...string) (*exec.Cmd, error)
+}
+
+// ExecRunner runs programs with os/exec.
+// When BinDir is set programs are only looked up in that directory,
+// which lets tests substitute fake binaries for the real tools.
+type ExecRunner struct {
+	BinDir string
+}
+
+// defaultRunner runs programs found in PATH
+var defaultRunner Runner = ExecRunner{}
+
+func (r ExecRunner) LookPath(name string) (string, error) {
+	if r.BinDir != "" {
+		return exec.LookPath(filepath.Join(r.BinDir, name))
+	}
+	return exec.LookPath(name)
+}
+
+func (r ExecRunner) command(name string, args ...string) (*exec.Cmd, error) {
+	path, err := r.LookPath(name)
+	if err != nil {
+		return nil, err
+	}
+	return exec.Command(path, args...), nil
+}
+
+func (r ExecRunner) Run(name string, args ...string) error {
+	cmd, err := r.command(name, args...)
+	if err != nil {
+		return err
+	}
+	return cmd.Run()
+}
+
+func (r ExecRunner) Output(name string, args ...string) ([]byte, error) {
+	cmd, err := r.command(name, args...)
+	if err != nil {
+		return nil, err
+	}
+	return cmd.Output()
+}
+
+func (r ExecRunner) Start(name string, args ...string) (*exec.Cmd, error) {
+	cmd, err := r.command(name, args...)
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return cmd, nil
+}
+
+func commandExists(cmd string) bool {
+	_, err := defaultRunner.LookPath(cmd)
+	return err == nil
+}
+
+// OpenURL opens url in the default browser
+func OpenURL(r Runner, url string) error {
+	if !haveBinaries(r, "xdg-open") {
+		return fmt.Errorf("opening URLs needs xdg-open")
+	}
+	_, err := r.Start("xdg-open", url)
+	return err
+}
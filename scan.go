@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"net/url"
+	"strings"
+
+	"github.com/makiuchi-d/gozxing"
+	"github.com/makiuchi-d/gozxing/aztec"
+	"github.com/makiuchi-d/gozxing/datamatrix"
+	"github.com/makiuchi-d/gozxing/oned"
+	"github.com/makiuchi-d/gozxing/qrcode"
+	"golang.org/x/image/draw"
+)
+
+// ScanResult is the content of a decoded QR code or barcode
+type ScanResult struct {
+	Text   string
+	Format string
+}
+
+// scanReaders returns the decoders to try, QR codes first
+func scanReaders() []gozxing.Reader {
+	return []gozxing.Reader{
+		qrcode.NewQRCodeReader(),
+		datamatrix.NewDataMatrixReader(),
+		aztec.NewAztecReader(),
+		oned.NewMultiFormatUPCEANReader(nil),
+		oned.NewCode128Reader(),
+		oned.NewCode39Reader(),
+		oned.NewCode93Reader(),
+		oned.NewCodaBarReader(),
+		oned.NewITFReader(),
+	}
+}
+
+// ScanImageFile decodes the first QR code or barcode found in the image
+func ScanImageFile(path string) (ScanResult, error) {
+	img, _, err := LoadImage(path)
+	if err != nil {
+		return ScanResult{}, err
+	}
+
+	hints := map[gozxing.DecodeHintType]interface{}{
+		gozxing.DecodeHintType_TRY_HARDER: true,
+	}
+
+	// Tight selections cut off the quiet zone decoders rely on. Dark themes
+	// often show codes in light-on-dark colours, those are padded with black
+	// before inverting so the quiet zone ends up white as well.
+	sources := []gozxing.LuminanceSource{
+		gozxing.NewLuminanceSourceFromImage(addQuietZone(img, color.White)),
+		gozxing.NewInvertedLuminanceSource(gozxing.NewLuminanceSourceFromImage(addQuietZone(img, color.Black))),
+	}
+	for _, luminance := range sources {
+		bitmap, err := gozxing.NewBinaryBitmap(gozxing.NewHybridBinarizer(luminance))
+		if err != nil {
+			return ScanResult{}, fmt.Errorf("failed to prepare image: %w", err)
+		}
+
+		for _, reader := range scanReaders() {
+			result, err := reader.Decode(bitmap, hints)
+			if err == nil {
+				return ScanResult{Text: result.GetText(), Format: result.GetBarcodeFormat().String()}, nil
+			}
+		}
+	}
+
+	return ScanResult{}, fmt.Errorf("no QR code or barcode found")
+}
+
+// addQuietZone surrounds img with a margin of a tenth of its size in c
+func addQuietZone(img image.Image, c color.Color) image.Image {
+	bounds := img.Bounds()
+	margin := max(8, max(bounds.Dx(), bounds.Dy())/10)
+
+	dst := image.NewRGBA(image.Rect(0, 0, bounds.Dx()+2*margin, bounds.Dy()+2*margin))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(c), image.Point{}, draw.Src)
+	draw.Draw(dst, image.Rect(margin, margin, margin+bounds.Dx(), margin+bounds.Dy()), img, bounds.Min, draw.Over)
+	return dst
+}
+
+// isWebURL reports whether text is a single http(s) URL
+func isWebURL(text string) bool {
+	if strings.ContainsAny(text, " \n\t") {
+		return false
+	}
+	parsed, err := url.Parse(text)
+	return err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
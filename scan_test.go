@@ -0,0 +1,65 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/makiuchi-d/gozxing"
+	"github.com/makiuchi-d/gozxing/qrcode"
+)
+
+// writeQRCode renders text as a QR code without a quiet zone, the way a
+// tight selection captures it, optionally light-on-dark
+func writeQRCode(t *testing.T, text string, inverted bool) string {
+	t.Helper()
+	hints := map[gozxing.EncodeHintType]interface{}{gozxing.EncodeHintType_MARGIN: 0}
+	matrix, err := qrcode.NewQRCodeWriter().Encode(text, gozxing.BarcodeFormat_QR_CODE, 200, 200, hints)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dark, light := color.Gray{Y: 0x20}, color.Gray{Y: 0xf0}
+	if inverted {
+		dark, light = light, dark
+	}
+	img := image.NewGray(image.Rect(0, 0, matrix.GetWidth(), matrix.GetHeight()))
+	for y := range matrix.GetHeight() {
+		for x := range matrix.GetWidth() {
+			if matrix.Get(x, y) {
+				img.SetGray(x, y, dark)
+			} else {
+				img.SetGray(x, y, light)
+			}
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "code.png")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if err := png.Encode(file, img); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestScanImageFile(t *testing.T) {
+	const text = "https://example.com/caplet"
+
+	for _, inverted := range []bool{false, true} {
+		result, err := ScanImageFile(writeQRCode(t, text, inverted))
+		if err != nil {
+			t.Errorf("inverted=%v: %v", inverted, err)
+			continue
+		}
+		if result.Text != text || result.Format != "QR_CODE" {
+			t.Errorf("inverted=%v: got %q (%s), want %q", inverted, result.Text, result.Format, text)
+		}
+	}
+}
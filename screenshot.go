@@ -1,227 +1,266 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"strings"
 	"time"
 )
 
-func commandExists(cmd string) bool {
-	_, err := exec.LookPath(cmd)
-	return err == nil
+// Display server sessions a backend can work in
+const (
+	SessionWayland = "wayland"
+	SessionX11     = "x11"
+)
+
+// CaptureRequest describes a single screenshot for a backend to take
+type CaptureRequest struct {
+	Region     bool   // Let the user select a region instead of the whole screen
+	Cursor     bool   // Include the mouse cursor, backends hide it otherwise
+	OutputPath string // PNG file the backend must write
+}
+
+// ScreenshotBackend is a screenshot tool caplet can drive
+type ScreenshotBackend interface {
+	// Name identifies the backend in config files and on the command line
+	Name() string
+	// Sessions lists the display servers the backend works with
+	Sessions() []string
+	// Available reports whether the tools the backend needs are installed
+	Available(r Runner) bool
+	// Capture takes the screenshot described by req
+	Capture(r Runner, req CaptureRequest) error
+}
+
+// AreaCapturer is implemented by backends that can capture a given screen
+// area without user interaction. Other backends capture the whole screen
+// and the area is cropped out afterwards.
+type AreaCapturer interface {
+	CaptureArea(r Runner, req CaptureRequest, area Rect) error
+}
+
+// OutputCapturer is implemented by backends that can capture a single
+// monitor by its output name
+type OutputCapturer interface {
+	CaptureOutput(r Runner, req CaptureRequest, output string) error
+}
+
+// CaptureOptions controls how TakeScreenshot picks and drives a backend
+type CaptureOptions struct {
+	Region   bool     // Select a screen region instead of capturing everything
+	Geometry *Rect    // Capture exactly this screen area, overrides Region
+	Output   string   // Output name of the monitor in Geometry, if capturing one
+	Cursor   bool     // Include the mouse cursor in the capture
+	Freeze   bool     // Select regions over a frozen image of the screen
+	Backend  string   // Use exactly this backend, empty to pick automatically
+	Order    []string // Detection order, empty for the default order of the session
+}
+
+var screenshotBackends []ScreenshotBackend
+
+// defaultBackendOrder is the order backends are tried in per session
+var defaultBackendOrder = map[string][]string{
+	SessionWayland: {"spectacle", "gnome-screenshot", "flameshot", "grim", "portal"},
+	SessionX11:     {"spectacle", "flameshot", "xfce4-screenshooter", "maim", "scrot", "portal"},
+}
+
+// RegisterBackend makes a screenshot backend available for selection
+func RegisterBackend(backend ScreenshotBackend) {
+	screenshotBackends = append(screenshotBackends, backend)
 }
 
-func TakeScreenshotWayland(region bool, outputPath string) error {
-	// --- 1. Spectacle (KDE)
-	if commandExists("spectacle") {
-		args := []string{"-n", "-b", "-o", outputPath}
-		if region {
-			args = append(args, "-r")
+// FindBackend returns the registered backend with the given name
+func FindBackend(name string) (ScreenshotBackend, bool) {
+	for _, backend := range screenshotBackends {
+		if backend.Name() == name {
+			return backend, true
 		}
-		cmd := exec.Command("spectacle", args...)
-		if err := cmd.Run(); err != nil {
-			return fmt.Errorf("spectacle failed: %w", err)
+	}
+	return nil, false
+}
+
+// CurrentSession returns the display server caplet is running under
+func CurrentSession() string {
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		return SessionWayland
+	}
+	return SessionX11
+}
+
+// haveBinaries reports whether all named programs can be found
+func haveBinaries(r Runner, names ...string) bool {
+	for _, name := range names {
+		if _, err := r.LookPath(name); err != nil {
+			return false
 		}
-		return nil
 	}
+	return true
+}
 
-	// --- 2. gnome-screenshot
-	if commandExists("gnome-screenshot") {
-		args := []string{}
-		if region {
-			args = append(args, "-a")
-		} else {
-			args = append(args, "-f", outputPath)
+// backendOrder returns the configured detection order or the session default
+func backendOrder(session string, order []string) []string {
+	if len(order) > 0 {
+		return order
+	}
+	return defaultBackendOrder[session]
+}
+
+// SelectBackend picks the backend to capture with.
+// An explicit backend must exist and be installed; otherwise the first
+// available backend in order that supports the session is used.
+func SelectBackend(r Runner, session string, explicit string, order []string) (ScreenshotBackend, error) {
+	if explicit != "" {
+		backend, found := FindBackend(explicit)
+		if !found {
+			return nil, fmt.Errorf("unknown screenshot backend %q, run 'caplet backends' to list them", explicit)
 		}
-		cmd := exec.Command("gnome-screenshot", args...)
-		if region {
-			cmd = exec.Command("gnome-screenshot", "-a")
-			if err := cmd.Run(); err != nil {
-				return fmt.Errorf("gnome-screenshot (region) failed: %w", err)
-			}
-			// No file output path support for region, user must manually save
-			return nil
+		if !slices.Contains(backend.Sessions(), session) {
+			return nil, fmt.Errorf("screenshot backend %q does not support %s sessions", explicit, session)
 		}
-		if err := cmd.Run(); err != nil {
-			return fmt.Errorf("gnome-screenshot failed: %w", err)
+		if !backend.Available(r) {
+			return nil, fmt.Errorf("screenshot backend %q is not installed", explicit)
 		}
-		return nil
+		return backend, nil
 	}
 
-	// --- 3. Flameshot
-	if commandExists("flameshot") {
-		args := []string{"gui"}
-		if !region {
-			args = append(args, "--fullscreen", "--path", outputPath)
+	for _, name := range backendOrder(session, order) {
+		backend, found := FindBackend(name)
+		if !found {
+			fmt.Fprintf(os.Stderr, "Ignoring unknown screenshot backend %q in backend order\n", name)
+			continue
 		}
-		cmd := exec.Command("flameshot", args...)
-		if err := cmd.Run(); err != nil {
-			return fmt.Errorf("flameshot failed: %w", err)
+		if slices.Contains(backend.Sessions(), session) && backend.Available(r) {
+			return backend, nil
 		}
-		return nil
 	}
 
-	// --- 4. grim + slurp (wlroots)
-	if !commandExists("grim") {
-		return fmt.Errorf("no compatible screenshot tool found. Compatible wayland screenshot tool: KDE Spectacle,  gnome-screenshot, flameshot, or grim + slurp")
+	var compatible []string
+	for _, backend := range screenshotBackends {
+		if slices.Contains(backend.Sessions(), session) {
+			compatible = append(compatible, backend.Name())
+		}
 	}
+	return nil, fmt.Errorf("no compatible screenshot tool found for %s. Compatible tools: %s", session, strings.Join(compatible, ", "))
+}
 
-	if region && !commandExists("slurp") {
-		return fmt.Errorf("slurp is required for region screenshots for grim but not found in PATH")
+// TakeScreenshot captures a screenshot
+func TakeScreenshot(opts CaptureOptions) (string, error) {
+	if runtime.GOOS != "linux" {
+		return "", fmt.Errorf("unsupported OS: %s", runtime.GOOS)
 	}
 
-	if region {
-		// Optional: freeze screen with hyprpicker if available
-		var hyprpickerProcess *exec.Cmd
-		var hyprpickerStarted bool
+	backend, err := SelectBackend(defaultRunner, CurrentSession(), opts.Backend, opts.Order)
+	if err != nil {
+		return "", err
+	}
 
-		if commandExists("hyprpicker") && commandExists("hyprctl") {
-			_ = exec.Command("hyprctl", "keyword", "layerrule", "noanim,selection").Run()
-			hyprpickerProcess = exec.Command("hyprpicker", "-r", "-z")
-			if err := hyprpickerProcess.Start(); err == nil {
-				hyprpickerStarted = true
-				time.Sleep(200 * time.Millisecond)
-			}
-		}
+	// Create a temporary directory for the screenshot
+	tempDir, err := os.MkdirTemp("", "caplet-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp directory: %w", err)
+	}
 
-		slurpCmd := exec.Command("slurp")
-		slurpOutput, err := slurpCmd.Output()
+	outputPath := filepath.Join(tempDir, fmt.Sprintf("screenshot-%s.png", time.Now().Format("2006-01-02_15-04-05")))
+	req := CaptureRequest{
+		Region:     opts.Region,
+		Cursor:     opts.Cursor,
+		OutputPath: outputPath,
+	}
 
-		if hyprpickerStarted && hyprpickerProcess != nil && hyprpickerProcess.Process != nil {
-			_ = hyprpickerProcess.Process.Kill()
+	if outputCapturer, ok := backend.(OutputCapturer); ok && opts.Output != "" {
+		if err := outputCapturer.CaptureOutput(defaultRunner, req, opts.Output); err != nil {
+			return "", err
 		}
+		return outputPath, nil
+	}
 
-		if err != nil {
-			return fmt.Errorf("failed to get region with slurp: %w", err)
+	if opts.Region && opts.Freeze {
+		frozen, err := freezeScreen(defaultRunner, CurrentSession())
+		if err == nil {
+			if err := captureFrozenRegion(defaultRunner, backend, req, frozen); err != nil {
+				return "", err
+			}
+			return outputPath, nil
 		}
+		fmt.Fprintf(os.Stderr, "Not freezing the screen: %v\n", err)
+	}
 
-		geometry := strings.TrimSpace(string(slurpOutput))
-		if geometry == "" {
-			return fmt.Errorf("no region selected")
+	if opts.Geometry != nil {
+		if err := captureArea(defaultRunner, backend, req, *opts.Geometry); err != nil {
+			return "", err
 		}
+		return outputPath, nil
+	}
 
-		grimCmd := exec.Command("grim", "-g", geometry, outputPath)
-		if err := grimCmd.Run(); err != nil {
-			return fmt.Errorf("grim (region) failed: %w", err)
-		}
-	} else {
-		grimCmd := exec.Command("grim", outputPath)
-		if err := grimCmd.Run(); err != nil {
-			return fmt.Errorf("grim (fullscreen) failed: %w", err)
-		}
+	if err := backend.Capture(defaultRunner, req); err != nil {
+		return "", err
 	}
 
-	return nil
+	return outputPath, nil
 }
 
-func TakeScreenshotX11(region bool, outputPath string) error {
-	if commandExists("spectacle") {
-		args := []string{"-n", "-b", "-o", outputPath}
-		if region {
-			args = append(args, "-r")
-		}
-		cmd := exec.Command("spectacle", args...)
-		if err := cmd.Run(); err != nil {
-			return fmt.Errorf("spectacle failed: %w", err)
-		}
-		return nil
+// captureArea captures a fixed screen area, cropping a fullscreen capture
+// for backends that cannot capture areas themselves. The area is in logical
+// screen coordinates, so the crop accounts for the layout's origin and for
+// HiDPI captures having more pixels.
+func captureArea(r Runner, backend ScreenshotBackend, req CaptureRequest, area Rect) error {
+	if areaCapturer, ok := backend.(AreaCapturer); ok {
+		return areaCapturer.CaptureArea(r, req, area)
 	}
 
-	if commandExists("flameshot") {
-		args := []string{"gui", "--path", outputPath}
-		if !region {
-			args = append(args, "--fullscreen")
-		}
-		cmd := exec.Command("flameshot", args...)
-		if err := cmd.Run(); err != nil {
-			return fmt.Errorf("flameshot failed: %w", err)
-		}
-		return nil
+	req.Region = false
+	if err := backend.Capture(r, req); err != nil {
+		return err
 	}
-
-	if commandExists("xfce4-screenshooter") {
-		args := []string{"-f", "-s", outputPath}
-		if region {
-			args = []string{"-r", "-s", outputPath}
-		}
-		cmd := exec.Command("xfce4-screenshooter", args...)
-		if err := cmd.Run(); err != nil {
-			return fmt.Errorf("xfce4-screenshooter failed: %w", err)
-		}
+	if !FileExists(req.OutputPath) {
 		return nil
 	}
 
-	if commandExists("maim") {
-		args := []string{outputPath}
-		if region {
-			if !commandExists("slop") {
-				return fmt.Errorf("maim requires 'slop' for region selection, but it was not found")
-			}
-			cmd := exec.Command("slop", "-f", "%x,%y,%w,%h")
-			output, err := cmd.Output()
-			if err != nil {
-				return fmt.Errorf("slop failed: %w", err)
-			}
-			coords := strings.TrimSpace(string(output))
-			parts := strings.Split(coords, ",")
-			if len(parts) != 4 {
-				return fmt.Errorf("invalid region format from slop")
-			}
-			args = []string{
-				"-g", fmt.Sprintf("%sx%s+%s+%s", parts[2], parts[3], parts[0], parts[1]),
-				outputPath,
-			}
-		}
-		cmd := exec.Command("maim", args...)
-		if err := cmd.Run(); err != nil {
-			return fmt.Errorf("maim failed: %w", err)
-		}
-		return nil
-	}
+	origin, scale := captureOrigin(req.OutputPath, screenArea(r))
+	return CropImageFile(req.OutputPath, scaleRect(area, origin, scale))
+}
+
+// RunBackendsCommand handles "caplet backends", listing known screenshot backends
+func RunBackendsCommand(config Config, args []string) error {
+	fs := flag.NewFlagSet("backends", flag.ExitOnError)
+	fs.Parse(args)
 
-	if commandExists("scrot") {
-		args := []string{outputPath}
-		if region {
-			args = []string{"-s", outputPath}
+	session := CurrentSession()
+	selected, selectErr := SelectBackend(defaultRunner, session, config.ScreenshotBackend, config.BackendOrder)
+
+	fmt.Printf("Session: %s\n", session)
+	fmt.Printf("Detection order: %s\n", strings.Join(backendOrder(session, config.BackendOrder), ", "))
+	fmt.Println()
+
+	for _, backend := range screenshotBackends {
+		status := "not installed"
+		if backend.Available(defaultRunner) {
+			status = "installed"
 		}
-		cmd := exec.Command("scrot", args...)
-		if err := cmd.Run(); err != nil {
-			return fmt.Errorf("scrot failed: %w", err)
+		if !slices.Contains(backend.Sessions(), session) {
+			status += ", not for " + session
 		}
-		return nil
-	}
 
-	return fmt.Errorf("no compatible screenshot tool found for X11")
-}
+		marker := " "
+		if selectErr == nil && backend.Name() == selected.Name() {
+			marker = "*"
+		}
 
-// TakeScreenshot captures a screenshot
-func TakeScreenshot(region bool) (string, error) {
-	// Create a temporary directory for the screenshot
-	tempDir, err := os.MkdirTemp("", "caplet-")
-	if err != nil {
-		return "", fmt.Errorf("failed to create temp directory: %w", err)
+		fmt.Printf("%s %-20s %-16s %s\n", marker, backend.Name(), strings.Join(backend.Sessions(), ","), status)
 	}
 
-	outputPath := filepath.Join(tempDir, fmt.Sprintf("screenshot-%s.png", time.Now().Format("2006-01-02_15-04-05")))
-	if runtime.GOOS == "linux" {
-		// Check if we're using Wayland or X11
-		waylandDisplay := os.Getenv("WAYLAND_DISPLAY")
-		if waylandDisplay != "" {
-			if err := TakeScreenshotWayland(region, outputPath); err != nil {
-				return "", err
-			}
-		} else {
-			if err := TakeScreenshotX11(region, outputPath); err != nil {
-				return "", err
-			}
-		}
+	fmt.Println()
+	if selectErr != nil {
+		fmt.Printf("No usable backend: %v\n", selectErr)
+	} else if config.ScreenshotBackend != "" {
+		fmt.Printf("* %s is selected in the config\n", selected.Name())
 	} else {
-		return "", fmt.Errorf("unsupported OS: %s", runtime.GOOS)
+		fmt.Printf("* %s will be used\n", selected.Name())
 	}
 
-	return outputPath, nil
+	return nil
 }
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"image"
+	"image/draw"
+	"image/png"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+const (
+	// minScrollOverlap is the number of non-blank rows two frames must share
+	// before their overlap is trusted
+	minScrollOverlap = 8
+	// minScrollMatch is the fraction of overlapping rows that must match,
+	// leaving room for blinking cursors and hover effects
+	minScrollMatch = 0.95
+	// scrollEndFrames is how many unchanged frames in a row end auto-scrolling
+	scrollEndFrames = 2
+)
+
+// scrollFrame is a captured frame with a hash per pixel row
+type scrollFrame struct {
+	img    *image.RGBA
+	rows   []uint64
+	plain  []bool // Rows of a single colour, which match anywhere
+	offset int    // Rows scrolled since the previous frame
+	bottom int    // Rows at the bottom unchanged since the previous frame
+}
+
+// StopScrollCapture asks a running "caplet -mode scroll" to finish.
+// It reports whether a scrolling capture was running.
+func StopScrollCapture() (bool, error) {
+	return SignalInstance("scroll", syscall.SIGINT)
+}
+
+// ScrollCapture repeatedly captures a selected region while it is scrolled,
+// either by the user or with ydotool/xdotool, and stitches the frames into
+// one tall image. Capturing ends on SIGINT/SIGTERM, usually sent by a second
+// "caplet -mode scroll", or when auto-scrolling reaches the end. It returns
+// an empty path if the selection was cancelled.
+func ScrollCapture(opts CaptureOptions, config ScrollConfig, showNotification bool) (string, error) {
+	if config.Auto {
+		if err := checkScrollTool(defaultRunner); err != nil {
+			return "", err
+		}
+	}
+
+	// A fixed area from -geometry replaces the selection
+	var area Rect
+	if opts.Geometry != nil {
+		area = *opts.Geometry
+	} else {
+		selected, ok, err := SelectArea(defaultRunner)
+		if err != nil {
+			return "", err
+		}
+		if !ok {
+			return "", nil
+		}
+		rememberRegion(selected)
+		area = selected
+	}
+	opts.Region = false
+	opts.Geometry = &area
+
+	if backend, err := SelectBackend(defaultRunner, CurrentSession(), opts.Backend, opts.Order); err == nil {
+		if _, ok := backend.(AreaCapturer); !ok {
+			fmt.Fprintf(os.Stderr, "%s cannot capture areas, every frame is cut out of a full screen capture\n", backend.Name())
+		}
+	}
+
+	removePidFile, err := WritePidFile("scroll")
+	if err != nil {
+		return "", err
+	}
+	defer removePidFile()
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(stop)
+
+	interval := time.Duration(config.Interval) * time.Millisecond
+	if interval <= 0 {
+		interval = 500 * time.Millisecond
+	}
+	maxFrames := config.MaxFrames
+	if maxFrames <= 0 {
+		maxFrames = 50
+	}
+
+	message := "Scroll through the region, run 'caplet -mode scroll' again or press Ctrl+C to finish."
+	if config.Auto {
+		message = "Scrolling... run 'caplet -mode scroll' again or press Ctrl+C to finish early."
+	}
+	fmt.Println(message)
+	if showNotification {
+		NOTIFY_ID, err = Notify(message, NOTIFY_ID, "")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to show notification: %v\n", err)
+		}
+	}
+
+	var frames []*scrollFrame
+	unchanged := 0
+capture:
+	for len(frames) < maxFrames {
+		frame, err := captureScrollFrame(opts)
+		if err != nil {
+			return "", err
+		}
+
+		if len(frames) == 0 {
+			frames = append(frames, frame)
+		} else if alignScrollFrame(frames[len(frames)-1], frame) {
+			frames = append(frames, frame)
+			unchanged = 0
+		} else {
+			unchanged++
+			if config.Auto && unchanged >= scrollEndFrames {
+				break
+			}
+		}
+
+		if config.Auto {
+			if err := scrollDown(defaultRunner, area, config.Amount); err != nil {
+				return "", err
+			}
+		}
+
+		select {
+		case <-stop:
+			break capture
+		case <-time.After(interval):
+		}
+	}
+
+	fmt.Printf("Captured %d frames.\n", len(frames))
+	go PlayCaptured()
+
+	tempDir, err := os.MkdirTemp("", "caplet-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	outputPath := filepath.Join(tempDir, fmt.Sprintf("scroll-%s.png", time.Now().Format("2006-01-02_15-04-05")))
+
+	file, err := os.Create(outputPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to create stitched image: %w", err)
+	}
+	defer file.Close()
+
+	if err := png.Encode(file, stitchScrollFrames(frames)); err != nil {
+		return "", fmt.Errorf("failed to encode stitched image: %w", err)
+	}
+
+	return outputPath, nil
+}
+
+// captureScrollFrame takes one screenshot of the scrolled region
+func captureScrollFrame(opts CaptureOptions) (*scrollFrame, error) {
+	path, err := TakeScreenshot(opts)
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(filepath.Dir(path))
+
+	img, _, err := LoadImage(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return newScrollFrame(img), nil
+}
+
+// newScrollFrame copies img into a frame and hashes its rows
+func newScrollFrame(img image.Image) *scrollFrame {
+	bounds := img.Bounds()
+	frame := &scrollFrame{
+		img:   image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy())),
+		rows:  make([]uint64, bounds.Dy()),
+		plain: make([]bool, bounds.Dy()),
+	}
+	draw.Draw(frame.img, frame.img.Bounds(), img, bounds.Min, draw.Src)
+
+	width := bounds.Dx() * 4
+	for y := range frame.rows {
+		row := frame.img.Pix[y*frame.img.Stride : y*frame.img.Stride+width]
+		hash := fnv.New64a()
+		hash.Write(row)
+		frame.rows[y] = hash.Sum64()
+
+		frame.plain[y] = true
+		for x := 4; x < width; x += 4 {
+			if row[x] != row[0] || row[x+1] != row[1] || row[x+2] != row[2] {
+				frame.plain[y] = false
+				break
+			}
+		}
+	}
+
+	return frame
+}
+
+// alignScrollFrame works out how far next was scrolled past prev and stores
+// it in next. Rows that stay put at the top and bottom, like sticky headers,
+// are left out of the comparison. It returns false if nothing changed.
+func alignScrollFrame(prev, next *scrollFrame) bool {
+	height := len(prev.rows)
+	if len(next.rows) != height || prev.img.Bounds() != next.img.Bounds() {
+		return false
+	}
+
+	top := 0
+	for top < height && prev.rows[top] == next.rows[top] {
+		top++
+	}
+	if top == height {
+		return false
+	}
+	bottom := 0
+	for bottom < height-top && prev.rows[height-1-bottom] == next.rows[height-1-bottom] {
+		bottom++
+	}
+	next.bottom = bottom
+
+	// Find the smallest shift where the end of prev lines up with the
+	// start of next, which keeps the largest overlap
+	end := height - bottom
+	bestOffset, bestScore := 0, 0.0
+	for offset := 1; offset < end-top; offset++ {
+		compared, matched := 0, 0
+		for y := top; y+offset < end; y++ {
+			if next.plain[y] {
+				continue
+			}
+			compared++
+			if prev.rows[y+offset] == next.rows[y] {
+				matched++
+			}
+		}
+		if compared < minScrollOverlap {
+			continue
+		}
+		score := float64(matched) / float64(compared)
+		if score > bestScore {
+			bestOffset, bestScore = offset, score
+		}
+		if score == 1 {
+			break
+		}
+	}
+
+	if bestScore < minScrollMatch {
+		// No overlap, most likely scrolled more than a screen at once
+		fmt.Fprintln(os.Stderr, "Could not find the overlap between two frames, scroll more slowly")
+		bestOffset = end - top
+	}
+	next.offset = bestOffset
+
+	return true
+}
+
+// stitchScrollFrames joins the frames along their scroll offsets, keeping
+// the rows that never scroll at the bottom only once
+func stitchScrollFrames(frames []*scrollFrame) *image.RGBA {
+	first := frames[0]
+	width, height := first.img.Bounds().Dx(), first.img.Bounds().Dy()
+
+	footer := 0
+	total := height
+	for i, frame := range frames[1:] {
+		if i == 0 || frame.bottom < footer {
+			footer = frame.bottom
+		}
+		total += frame.offset
+	}
+
+	stitched := image.NewRGBA(image.Rect(0, 0, width, total))
+	draw.Draw(stitched, image.Rect(0, 0, width, height-footer), first.img, image.Point{}, draw.Src)
+
+	y := height - footer
+	for _, frame := range frames[1:] {
+		src := image.Pt(0, height-footer-frame.offset)
+		draw.Draw(stitched, image.Rect(0, y, width, y+frame.offset), frame.img, src, draw.Src)
+		y += frame.offset
+	}
+
+	last := frames[len(frames)-1]
+	draw.Draw(stitched, image.Rect(0, y, width, total), last.img, image.Pt(0, height-footer), draw.Src)
+
+	return stitched
+}
+
+// checkScrollTool makes sure scroll events can be sent in this session
+func checkScrollTool(r Runner) error {
+	if CurrentSession() == SessionX11 {
+		if !haveBinaries(r, "xdotool") {
+			return fmt.Errorf("auto-scrolling on X11 needs xdotool")
+		}
+		return nil
+	}
+	if !haveBinaries(r, "ydotool") {
+		return fmt.Errorf("auto-scrolling on Wayland needs ydotool (with ydotoold running)")
+	}
+	return nil
+}
+
+// scrollDown sends mouse wheel clicks to scroll the content of area down.
+// ydotool can't place the pointer reliably, so on Wayland it must already
+// be over the area, which it is right after selecting it.
+func scrollDown(r Runner, area Rect, clicks int) error {
+	if clicks <= 0 {
+		clicks = 5
+	}
+
+	if CurrentSession() == SessionX11 {
+		centerX := strconv.Itoa(area.X + area.W/2)
+		centerY := strconv.Itoa(area.Y + area.H/2)
+		if err := r.Run("xdotool", "mousemove", centerX, centerY, "click", "--repeat", strconv.Itoa(clicks), "--delay", "20", "5"); err != nil {
+			return fmt.Errorf("xdotool failed to scroll: %w", err)
+		}
+		return nil
+	}
+
+	if err := r.Run("ydotool", "mousemove", "--wheel", "-x", "0", "-y", strconv.Itoa(-clicks)); err != nil {
+		return fmt.Errorf("ydotool failed to scroll: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+)
+
+const scrollTestWidth = 40
+
+// scrollDocument is a page whose rows all differ, so every scroll
+// position can be told apart
+func scrollDocument(height int) *image.RGBA {
+	doc := image.NewRGBA(image.Rect(0, 0, scrollTestWidth, height))
+	for y := range height {
+		for x := range scrollTestWidth {
+			doc.SetRGBA(x, y, color.RGBA{R: uint8(y), G: uint8(y / 256), B: uint8(x * 5), A: 255})
+		}
+	}
+	return doc
+}
+
+// bar is a fixed strip like a sticky header or footer
+func bar(height int, shade uint8) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, scrollTestWidth, height))
+	for y := range height {
+		for x := range scrollTestWidth {
+			img.SetRGBA(x, y, color.RGBA{R: shade, G: uint8(x), B: uint8(y), A: 255})
+		}
+	}
+	return img
+}
+
+// stack joins images vertically
+func stack(parts ...image.Image) *image.RGBA {
+	height := 0
+	for _, part := range parts {
+		height += part.Bounds().Dy()
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, scrollTestWidth, height))
+	y := 0
+	for _, part := range parts {
+		b := part.Bounds()
+		for py := range b.Dy() {
+			for px := range b.Dx() {
+				dst.Set(px, y+py, part.At(b.Min.X+px, b.Min.Y+py))
+			}
+		}
+		y += b.Dy()
+	}
+	return dst
+}
+
+// window returns rows top to top+height of doc
+func window(doc *image.RGBA, top int, height int) image.Image {
+	return doc.SubImage(image.Rect(0, top, scrollTestWidth, top+height))
+}
+
+// alignAll aligns each frame with the one before, as ScrollCapture does
+func alignAll(t *testing.T, frames []*scrollFrame) {
+	t.Helper()
+	for i := 1; i < len(frames); i++ {
+		if !alignScrollFrame(frames[i-1], frames[i]) {
+			t.Fatalf("frame %d was reported unchanged", i)
+		}
+	}
+}
+
+func sameImage(a image.Image, b image.Image) bool {
+	if a.Bounds().Size() != b.Bounds().Size() {
+		return false
+	}
+	return bytes.Equal(stack(a).Pix, stack(b).Pix)
+}
+
+func TestAlignScrollFrameOffset(t *testing.T) {
+	doc := scrollDocument(240)
+	frames := []*scrollFrame{
+		newScrollFrame(window(doc, 0, 80)),
+		newScrollFrame(window(doc, 25, 80)),
+		newScrollFrame(window(doc, 60, 80)),
+	}
+	alignAll(t, frames)
+
+	for i, want := range []int{25, 35} {
+		if got := frames[i+1].offset; got != want {
+			t.Errorf("frame %d: got offset %d, want %d", i+1, got, want)
+		}
+		if frames[i+1].bottom != 0 {
+			t.Errorf("frame %d: got %d fixed bottom rows, want 0", i+1, frames[i+1].bottom)
+		}
+	}
+
+	stitched := stitchScrollFrames(frames)
+	if want := window(doc, 0, 140); !sameImage(stitched, want) {
+		t.Errorf("stitched image of %v does not match the document's first 140 rows", stitched.Bounds())
+	}
+}
+
+func TestAlignScrollFrameStickyBars(t *testing.T) {
+	doc := scrollDocument(240)
+	header, footer := bar(10, 250), bar(12, 200)
+	frame := func(top int) *scrollFrame {
+		return newScrollFrame(stack(header, window(doc, top, 60), footer))
+	}
+
+	frames := []*scrollFrame{frame(0), frame(20), frame(45)}
+	alignAll(t, frames)
+
+	for i, want := range []int{20, 25} {
+		if got := frames[i+1].offset; got != want {
+			t.Errorf("frame %d: got offset %d, want %d", i+1, got, want)
+		}
+		if got := frames[i+1].bottom; got != 12 {
+			t.Errorf("frame %d: got %d fixed bottom rows, want 12", i+1, got)
+		}
+	}
+
+	// The header stays on top and the footer appears once at the bottom
+	stitched := stitchScrollFrames(frames)
+	if want := stack(header, window(doc, 0, 105), footer); !sameImage(stitched, want) {
+		t.Errorf("stitched image of %v does not match header, 105 document rows and footer", stitched.Bounds())
+	}
+}
+
+func TestAlignScrollFrameUnchanged(t *testing.T) {
+	doc := scrollDocument(240)
+	prev := newScrollFrame(window(doc, 30, 80))
+	next := newScrollFrame(window(doc, 30, 80))
+
+	if alignScrollFrame(prev, next) {
+		t.Error("identical frames were reported as scrolled")
+	}
+
+	// A frame of another size can't be part of the same capture
+	if alignScrollFrame(prev, newScrollFrame(window(doc, 30, 70))) {
+		t.Error("frames of different sizes were aligned")
+	}
+}
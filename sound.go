@@ -18,6 +18,7 @@ const (
 	Captured = "sounds/CaptureSound.wav"       // Success/completion sound
 	Uploaded = "sounds/TaskCompletedSound.wav" // Secondary success sound
 	Error    = "sounds/ErrorSound.wav"         // Error notification sound
+	Tick     = "sounds/TickSound.wav"          // Countdown tick sound
 )
 
 var (
@@ -40,7 +41,7 @@ func extractSoundFiles() error {
 		}
 
 		// Extract sound files
-		files := []string{Captured, Uploaded, Error}
+		files := []string{Captured, Uploaded, Error, Tick}
 		for _, file := range files {
 			// Read embedded file
 			data, err := fs.ReadFile(soundFiles, file)
@@ -139,6 +140,12 @@ func PlayError() error {
 	return PlaySound(Error)
 }
 
+// PlayTick plays the TickSound sound.
+// Use this for each second of a capture countdown.
+func PlayTick() error {
+	return PlaySound(Tick)
+}
+
 // Cleanup removes the temporary directory and extracted files
 // Call this when your application shuts down
 func Cleanup() {
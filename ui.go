@@ -0,0 +1,350 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// uiServer serves the local history browser
+type uiServer struct {
+	config      Config
+	historyPath string
+	savePath    string
+	host        string
+
+	// Guards read-modify-write cycles on history.json
+	mu sync.Mutex
+}
+
+// uiEntry is a history entry as sent to the web UI
+type uiEntry struct {
+	Upload
+	ID      int    `json:"id"`
+	Name    string `json:"name"`
+	IsImage bool   `json:"isImage"`
+	Local   bool   `json:"local"`
+	Status  string `json:"status"`
+}
+
+// RunUICommand handles "caplet ui"
+func RunUICommand(config Config, args []string) error {
+	fs := flag.NewFlagSet("ui", flag.ExitOnError)
+	portFlag := fs.Int("port", 8787, "Port to listen on (localhost only)")
+	openFlag := fs.Bool("open", true, "Open the UI in the default browser")
+	historyPath := fs.String("history", config.HistoryPath, "Folder path to upload history")
+	savePath := fs.String("save", config.SaveDir, "Folder path to upload screenshots/files")
+	fs.Parse(args)
+
+	listener, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(*portFlag)))
+	if err != nil {
+		return fmt.Errorf("failed to listen: %w", err)
+	}
+
+	server := &uiServer{
+		config:      config,
+		historyPath: *historyPath,
+		savePath:    *savePath,
+		host:        listener.Addr().String(),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", server.handleIndex)
+	mux.HandleFunc("GET /api/history", server.handleHistory)
+	mux.HandleFunc("GET /api/thumb/{id}", server.handleThumb)
+	mux.HandleFunc("GET /api/file/{id}", server.handleFile)
+	mux.HandleFunc("POST /api/copy/{id}", server.handleCopy)
+	mux.HandleFunc("POST /api/reupload/{id}", server.handleReupload)
+	mux.HandleFunc("POST /api/delete/{id}", server.handleDelete)
+	mux.HandleFunc("POST /api/upload", server.handleUpload)
+
+	url := "http://" + server.host + "/"
+	fmt.Printf("Caplet UI running at %s (Ctrl+C to stop)\n", url)
+
+	if *openFlag && commandExists("xdg-open") {
+		if err := OpenURL(defaultRunner, url); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to open browser: %v\n", err)
+		}
+	}
+
+	return http.Serve(listener, server.guard(mux))
+}
+
+// guard rejects requests that did not come from the UI page itself.
+// Checking the Host header stops DNS rebinding, and requiring a custom
+// header on writes stops other sites from posting forms to us.
+func (s *uiServer) guard(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isLocalHost(r, s.host) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		if r.Method != http.MethodGet && r.Header.Get("X-Caplet") != "1" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isLocalHost reports whether the request was addressed to our listener,
+// either by its address or as localhost
+func isLocalHost(r *http.Request, listenAddr string) bool {
+	_, port, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		return false
+	}
+	return r.Host == listenAddr || r.Host == "localhost:"+port
+}
+
+func (s *uiServer) handleIndex(w http.ResponseWriter, r *http.Request) {
+	page, err := webFiles.ReadFile("web/ui.html")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(page)
+}
+
+func (s *uiServer) handleHistory(w http.ResponseWriter, r *http.Request) {
+	history, err := LoadHistory(s.historyPath)
+	if err != nil {
+		writeJSONError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	entries := make([]uiEntry, 0, len(history))
+	// Newest uploads first
+	for i := len(history) - 1; i >= 0; i-- {
+		upload := history[i]
+		entries = append(entries, uiEntry{
+			Upload:  upload,
+			ID:      i,
+			Name:    filepath.Base(upload.File),
+			IsImage: ImageExtensions[strings.ToLower(filepath.Ext(upload.File))],
+			Local:   FileExists(upload.File),
+			Status:  DeletionStatus(upload),
+		})
+	}
+
+	services := make([]string, 0, len(s.config.Uploaders))
+	for name := range s.config.Uploaders {
+		services = append(services, name)
+	}
+	slices.Sort(services)
+
+	writeJSON(w, map[string]any{
+		"entries":  entries,
+		"services": services,
+	})
+}
+
+func (s *uiServer) handleThumb(w http.ResponseWriter, r *http.Request) {
+	upload, _, err := s.lookup(r)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	thumb, err := Thumbnail(upload.File, 320)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/jpeg")
+	w.Header().Set("Cache-Control", "max-age=3600")
+	w.Write(thumb)
+}
+
+func (s *uiServer) handleFile(w http.ResponseWriter, r *http.Request) {
+	upload, _, err := s.lookup(r)
+	if err != nil || !FileExists(upload.File) {
+		http.NotFound(w, r)
+		return
+	}
+
+	http.ServeFile(w, r, upload.File)
+}
+
+func (s *uiServer) handleCopy(w http.ResponseWriter, r *http.Request) {
+	upload, _, err := s.lookup(r)
+	if err != nil {
+		writeJSONError(w, err, http.StatusNotFound)
+		return
+	}
+
+	if err := CopyToClipboard(upload.URL, "text"); err != nil {
+		writeJSONError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, map[string]string{"url": upload.URL})
+}
+
+func (s *uiServer) handleReupload(w http.ResponseWriter, r *http.Request) {
+	upload, id, err := s.lookup(r)
+	if err != nil {
+		writeJSONError(w, err, http.StatusNotFound)
+		return
+	}
+
+	if !FileExists(upload.File) {
+		writeJSONError(w, fmt.Errorf("local copy %s no longer exists", upload.File), http.StatusBadRequest)
+		return
+	}
+
+	serviceName := r.URL.Query().Get("service")
+	service, found := s.config.Uploaders[serviceName]
+	if !found {
+		writeJSONError(w, fmt.Errorf("unknown uploader %q", serviceName), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	url, err := ReuploadHistoryEntry(s.config, s.historyPath, id, service)
+	s.mu.Unlock()
+	if err != nil {
+		writeJSONError(w, err, http.StatusBadGateway)
+		return
+	}
+
+	writeJSON(w, map[string]string{"url": url})
+}
+
+func (s *uiServer) handleDelete(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	upload, id, err := s.lookup(r)
+	if err != nil {
+		writeJSONError(w, err, http.StatusNotFound)
+		return
+	}
+
+	if err := DeleteRemoteUpload(upload); err != nil {
+		writeJSONError(w, err, http.StatusBadGateway)
+		return
+	}
+
+	history, err := LoadHistory(s.historyPath)
+	if err != nil {
+		writeJSONError(w, err, http.StatusInternalServerError)
+		return
+	}
+	// History may have changed on disk while the remote delete ran
+	if id >= len(history) || history[id].URL != upload.URL {
+		writeJSONError(w, fmt.Errorf("history changed, reload and try again"), http.StatusConflict)
+		return
+	}
+	history[id].Deleted = true
+	if err := WriteHistory(s.historyPath, history); err != nil {
+		writeJSONError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, map[string]string{"status": DeletionStatus(history[id])})
+}
+
+func (s *uiServer) handleUpload(w http.ResponseWriter, r *http.Request) {
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		writeJSONError(w, fmt.Errorf("no file in request: %w", err), http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	// Only keep the base name so a crafted file name cannot escape tempDir
+	name := filepath.Base(header.Filename)
+	if name == "." || name == ".." || name == string(filepath.Separator) {
+		writeJSONError(w, fmt.Errorf("uploaded file has no name"), http.StatusBadRequest)
+		return
+	}
+
+	tempDir, err := os.MkdirTemp("", "caplet-")
+	if err != nil {
+		writeJSONError(w, err, http.StatusInternalServerError)
+		return
+	}
+	defer os.RemoveAll(tempDir)
+
+	filePath := filepath.Join(tempDir, name)
+	dst, err := os.Create(filePath)
+	if err != nil {
+		writeJSONError(w, err, http.StatusInternalServerError)
+		return
+	}
+	_, err = io.Copy(dst, file)
+	dst.Close()
+	if err != nil {
+		writeJSONError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	serviceName := r.FormValue("service")
+	if serviceName == "" {
+		serviceName = s.config.DefaultFileUpload
+		if ImageExtensions[strings.ToLower(filepath.Ext(filePath))] {
+			serviceName = s.config.DefaultImageUpload
+		}
+	}
+
+	service, found := s.config.Uploaders[serviceName]
+	if !found {
+		writeJSONError(w, fmt.Errorf("no upload service configured for %q", serviceName), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	opts := &UploadOptions{StripMetadata: ResolveStripMetadata(s.config, service)}
+	url, err := UploadFile(filePath, service, false, s.historyPath, s.savePath, s.config.Organized, opts)
+	s.mu.Unlock()
+	if err != nil {
+		writeJSONError(w, err, http.StatusBadGateway)
+		return
+	}
+
+	writeJSON(w, map[string]string{"url": url})
+}
+
+// lookup returns the history entry addressed by the {id} path segment
+func (s *uiServer) lookup(r *http.Request) (Upload, int, error) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return Upload{}, 0, fmt.Errorf("invalid id: %w", err)
+	}
+
+	history, err := LoadHistory(s.historyPath)
+	if err != nil {
+		return Upload{}, 0, err
+	}
+
+	if id < 0 || id >= len(history) {
+		return Upload{}, 0, fmt.Errorf("no history entry with id %d", id)
+	}
+
+	return history[id], id, nil
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeJSONError(w http.ResponseWriter, err error, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
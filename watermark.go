@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"strings"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// addWatermark composites the task's image or text onto img
+func addWatermark(img image.Image, task PipelineTask) (image.Image, error) {
+	var overlay image.Image
+	var err error
+	switch {
+	case task.Image != "":
+		overlay, err = loadWatermarkImage(task)
+	case task.Text != "":
+		overlay, err = renderWatermarkText(task)
+	default:
+		return nil, fmt.Errorf("needs an image or text")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	opacity := task.Opacity
+	if opacity <= 0 || opacity > 1 {
+		opacity = 1
+	}
+	margin := 16
+	if task.Margin != nil {
+		if *task.Margin < 0 {
+			return nil, fmt.Errorf("margin must not be negative")
+		}
+		margin = *task.Margin
+	}
+
+	bounds := img.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, bounds.Min, draw.Src)
+
+	at, err := watermarkPosition(dst.Bounds(), overlay.Bounds(), task.Position, margin)
+	if err != nil {
+		return nil, err
+	}
+
+	mask := image.NewUniform(color.Alpha{A: uint8(opacity * 255)})
+	target := overlay.Bounds().Sub(overlay.Bounds().Min).Add(at)
+	draw.DrawMask(dst, target, overlay, overlay.Bounds().Min, mask, image.Point{}, draw.Over)
+
+	return dst, nil
+}
+
+// loadWatermarkImage loads the logo, scaled to the task's width if given
+func loadWatermarkImage(task PipelineTask) (image.Image, error) {
+	path := strings.ReplaceAll(task.Image, "$HOME", os.Getenv("HOME"))
+	logo, _, err := LoadImage(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if task.Width > 0 {
+		bounds := logo.Bounds()
+		height := max(1, bounds.Dy()*task.Width/bounds.Dx())
+		logo = ScaleImage(logo, task.Width, height)
+	}
+
+	return logo, nil
+}
+
+// renderWatermarkText draws the task's text onto a transparent image,
+// using the TTF/OTF file in task.Font or Go Bold by default
+func renderWatermarkText(task PipelineTask) (image.Image, error) {
+	fontData := gobold.TTF
+	if task.Font != "" {
+		data, err := os.ReadFile(strings.ReplaceAll(task.Font, "$HOME", os.Getenv("HOME")))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read font: %w", err)
+		}
+		fontData = data
+	}
+
+	parsed, err := opentype.Parse(fontData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse font: %w", err)
+	}
+
+	size := task.FontSize
+	if size <= 0 {
+		size = 32
+	}
+	face, err := opentype.NewFace(parsed, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load font face: %w", err)
+	}
+	defer face.Close()
+
+	textColor, err := parseHexColor(task.Color, color.White)
+	if err != nil {
+		return nil, err
+	}
+
+	drawer := &font.Drawer{Face: face, Src: image.NewUniform(textColor)}
+	textBounds, _ := drawer.BoundString(task.Text)
+	width := (textBounds.Max.X - textBounds.Min.X).Ceil()
+	height := (textBounds.Max.Y - textBounds.Min.Y).Ceil()
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("text %q has nothing to draw", task.Text)
+	}
+
+	canvas := image.NewRGBA(image.Rect(0, 0, width, height))
+	drawer.Dst = canvas
+	drawer.Dot = fixed.Point26_6{X: -textBounds.Min.X, Y: -textBounds.Min.Y}
+	drawer.DrawString(task.Text)
+
+	return canvas, nil
+}
+
+// watermarkPosition returns the top-left corner for an overlay of the given
+// size placed at position ("bottom-right" by default)
+func watermarkPosition(canvas image.Rectangle, overlay image.Rectangle, position string, margin int) (image.Point, error) {
+	width, height := overlay.Dx(), overlay.Dy()
+	left := margin
+	right := canvas.Dx() - width - margin
+	top := margin
+	bottom := canvas.Dy() - height - margin
+	centerX := (canvas.Dx() - width) / 2
+	centerY := (canvas.Dy() - height) / 2
+
+	switch position {
+	case "", "bottom-right":
+		return image.Pt(right, bottom), nil
+	case "bottom-left":
+		return image.Pt(left, bottom), nil
+	case "top-left":
+		return image.Pt(left, top), nil
+	case "top-right":
+		return image.Pt(right, top), nil
+	case "top":
+		return image.Pt(centerX, top), nil
+	case "bottom":
+		return image.Pt(centerX, bottom), nil
+	case "center":
+		return image.Pt(centerX, centerY), nil
+	}
+
+	return image.Point{}, fmt.Errorf("unknown position %q", position)
+}
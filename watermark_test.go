@@ -0,0 +1,57 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWatermarkMargin(t *testing.T) {
+	logo := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	draw.Draw(logo, logo.Bounds(), image.NewUniform(color.RGBA{R: 255, A: 255}), image.Point{}, draw.Src)
+	logoPath := filepath.Join(t.TempDir(), "logo.png")
+	file, err := os.Create(logoPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(file, logo); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	base := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	draw.Draw(base, base.Bounds(), image.White, image.Point{}, draw.Src)
+
+	zero, negative := 0, -1
+	tests := []struct {
+		name    string
+		margin  *int
+		corner  color.RGBA // Bottom-right pixel
+		wantErr bool
+	}{
+		{"default margin", nil, color.RGBA{R: 255, G: 255, B: 255, A: 255}, false},
+		{"zero margin", &zero, color.RGBA{R: 255, A: 255}, false},
+		{"negative margin", &negative, color.RGBA{}, true},
+	}
+
+	for _, tt := range tests {
+		result, err := addWatermark(base, PipelineTask{Type: "watermark", Image: logoPath, Margin: tt.margin})
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected an error", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got := color.RGBAModel.Convert(result.At(63, 63)); got != tt.corner {
+			t.Errorf("%s: bottom-right pixel is %v, want %v", tt.name, got, tt.corner)
+		}
+	}
+}
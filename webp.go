@@ -0,0 +1,517 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"io"
+	"sort"
+
+	"golang.org/x/image/draw"
+)
+
+// Go only ships a WebP decoder, so WebP output uses this small lossless
+// (VP8L) encoder: the subtract green transform, a color cache and LZ77
+// backward references, coded with a single set of prefix codes.
+// The format is described at https://developers.google.com/speed/webp/docs/webp_lossless_bitstream_specification
+
+const (
+	vp8lMaxDimension = 1 << 14
+	vp8lCacheBits    = 10
+	vp8lHashBits     = 16
+	vp8lChainDepth   = 32
+	vp8lMinMatch     = 3
+	vp8lMaxMatch     = 4096
+	// Largest distance the 40 distance prefix codes can express
+	vp8lMaxDistance = 1<<20 - 120
+)
+
+// Green, red, blue, alpha and distance alphabets
+var vp8lAlphabetSizes = [5]int{256 + 24 + 1<<vp8lCacheBits, 256, 256, 256, 40}
+
+// Order in which the code length code lengths are written
+var vp8lCodeLengthOrder = [19]int{17, 18, 0, 1, 2, 3, 4, 5, 16, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+// The 120 short distance codes, each byte holds a row offset in the high
+// nibble and 8 minus the column offset in the low one. Nearby pixels in the
+// rows above get the smallest codes.
+var vp8lDistanceMap = [120]uint8{
+	0x18, 0x07, 0x17, 0x19, 0x28, 0x06, 0x27, 0x29, 0x16, 0x1a,
+	0x26, 0x2a, 0x38, 0x05, 0x37, 0x39, 0x15, 0x1b, 0x36, 0x3a,
+	0x25, 0x2b, 0x48, 0x04, 0x47, 0x49, 0x14, 0x1c, 0x35, 0x3b,
+	0x46, 0x4a, 0x24, 0x2c, 0x58, 0x45, 0x4b, 0x34, 0x3c, 0x03,
+	0x57, 0x59, 0x13, 0x1d, 0x56, 0x5a, 0x23, 0x2d, 0x44, 0x4c,
+	0x55, 0x5b, 0x33, 0x3d, 0x68, 0x02, 0x67, 0x69, 0x12, 0x1e,
+	0x66, 0x6a, 0x22, 0x2e, 0x54, 0x5c, 0x43, 0x4d, 0x65, 0x6b,
+	0x32, 0x3e, 0x78, 0x01, 0x77, 0x79, 0x53, 0x5d, 0x11, 0x1f,
+	0x64, 0x6c, 0x42, 0x4e, 0x76, 0x7a, 0x21, 0x2f, 0x75, 0x7b,
+	0x31, 0x3f, 0x63, 0x6d, 0x52, 0x5e, 0x00, 0x74, 0x7c, 0x41,
+	0x4f, 0x10, 0x20, 0x62, 0x6e, 0x30, 0x73, 0x7d, 0x51, 0x5f,
+	0x40, 0x72, 0x7e, 0x61, 0x6f, 0x50, 0x71, 0x7f, 0x60, 0x70,
+}
+
+// EncodeWebP writes img as a lossless WebP
+func EncodeWebP(w io.Writer, img image.Image) error {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width < 1 || height < 1 || width > vp8lMaxDimension || height > vp8lMaxDimension {
+		return fmt.Errorf("WebP images must be between 1x1 and %dx%d pixels, got %dx%d", vp8lMaxDimension, vp8lMaxDimension, width, height)
+	}
+
+	// VP8L stores unpremultiplied ARGB
+	nrgba := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(nrgba, nrgba.Bounds(), img, bounds.Min, draw.Src)
+
+	pixels := make([]uint32, width*height)
+	alphaUsed := false
+	for i := range pixels {
+		p := nrgba.Pix[4*i : 4*i+4 : 4*i+4]
+		if p[3] != 0xff {
+			alphaUsed = true
+		}
+		// Subtract green, red and blue often correlate with it
+		r, g, b := p[0]-p[1], p[1], p[2]-p[1]
+		pixels[i] = uint32(p[3])<<24 | uint32(r)<<16 | uint32(g)<<8 | uint32(b)
+	}
+
+	bw := &vp8lBitWriter{}
+	bw.write(0x2f, 8) // Signature
+	bw.write(uint32(width-1), 14)
+	bw.write(uint32(height-1), 14)
+	bw.write(boolBit(alphaUsed), 1)
+	bw.write(0, 3) // Version
+	bw.write(1, 1) // A transform follows: subtract green
+	bw.write(2, 2)
+	bw.write(0, 1) // No further transforms
+	bw.write(1, 1) // Color cache
+	bw.write(vp8lCacheBits, 4)
+	bw.write(0, 1) // One prefix code group for the whole image
+
+	tokens := vp8lTokens(pixels, width)
+
+	var histograms [5][]int
+	for i, size := range vp8lAlphabetSizes {
+		histograms[i] = make([]int, size)
+	}
+	for _, t := range tokens {
+		switch t.kind {
+		case vp8lLiteral:
+			histograms[0][t.value>>8&0xff]++
+			histograms[1][t.value>>16&0xff]++
+			histograms[2][t.value&0xff]++
+			histograms[3][t.value>>24]++
+		case vp8lCache:
+			histograms[0][256+24+t.value]++
+		case vp8lCopy:
+			lengthCode, _, _ := vp8lPrefix(t.length)
+			distanceCode, _, _ := vp8lPrefix(int(t.value))
+			histograms[0][256+lengthCode]++
+			histograms[4][distanceCode]++
+		}
+	}
+
+	var codes [5]vp8lPrefixCode
+	for i := range codes {
+		codes[i] = newVP8LPrefixCode(histograms[i], 15)
+		codes[i].writeTo(bw)
+	}
+
+	for _, t := range tokens {
+		switch t.kind {
+		case vp8lLiteral:
+			codes[0].emit(bw, int(t.value>>8&0xff))
+			codes[1].emit(bw, int(t.value>>16&0xff))
+			codes[2].emit(bw, int(t.value&0xff))
+			codes[3].emit(bw, int(t.value>>24))
+		case vp8lCache:
+			codes[0].emit(bw, 256+24+int(t.value))
+		case vp8lCopy:
+			code, bits, extra := vp8lPrefix(t.length)
+			codes[0].emit(bw, 256+code)
+			bw.write(extra, bits)
+			code, bits, extra = vp8lPrefix(int(t.value))
+			codes[4].emit(bw, code)
+			bw.write(extra, bits)
+		}
+	}
+
+	data := bw.bytes()
+	chunkSize := len(data) + len(data)%2
+	header := make([]byte, 20)
+	copy(header[0:4], "RIFF")
+	binary.LittleEndian.PutUint32(header[4:8], uint32(12+chunkSize))
+	copy(header[8:12], "WEBP")
+	copy(header[12:16], "VP8L")
+	binary.LittleEndian.PutUint32(header[16:20], uint32(len(data)))
+	if len(data)%2 == 1 {
+		data = append(data, 0)
+	}
+
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err := w.Write(data)
+	return err
+}
+
+func boolBit(b bool) uint32 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// vp8lBitWriter packs values least significant bit first
+type vp8lBitWriter struct {
+	buf  []byte
+	bits uint64
+	n    uint
+}
+
+func (w *vp8lBitWriter) write(value uint32, n uint) {
+	w.bits |= uint64(value) << w.n
+	w.n += n
+	for w.n >= 8 {
+		w.buf = append(w.buf, byte(w.bits))
+		w.bits >>= 8
+		w.n -= 8
+	}
+}
+
+func (w *vp8lBitWriter) bytes() []byte {
+	if w.n > 0 {
+		w.buf = append(w.buf, byte(w.bits))
+		w.bits, w.n = 0, 0
+	}
+	return w.buf
+}
+
+const (
+	vp8lLiteral = iota
+	vp8lCache
+	vp8lCopy
+)
+
+// vp8lToken is one coded symbol: a literal ARGB pixel, a color cache index,
+// or a backward reference with its length and distance code in value
+type vp8lToken struct {
+	kind   uint8
+	value  uint32
+	length int
+}
+
+// vp8lTokens turns the pixels into literals, color cache hits and
+// backward references, greedily taking the longest match found
+func vp8lTokens(pixels []uint32, width int) []vp8lToken {
+	// The short distance codes for this width, keeping the smallest
+	// code where several map to the same distance
+	distanceCodes := map[int]int{}
+	for i, offset := range vp8lDistanceMap {
+		distance := max(1, int(offset>>4)*width+8-int(offset&0xf))
+		if _, found := distanceCodes[distance]; !found {
+			distanceCodes[distance] = i + 1
+		}
+	}
+
+	head := make([]int32, 1<<vp8lHashBits)
+	for i := range head {
+		head[i] = -1
+	}
+	chain := make([]int32, len(pixels))
+	hash := func(i int) uint32 {
+		return (pixels[i]*0x1e35a7bd ^ pixels[i+1]*0x9e3779b1) >> (32 - vp8lHashBits)
+	}
+	insert := func(i int) {
+		if i+1 < len(pixels) {
+			h := hash(i)
+			chain[i] = head[h]
+			head[h] = int32(i)
+		}
+	}
+	matchLength := func(i int, j int) int {
+		limit := min(vp8lMaxMatch, len(pixels)-i)
+		n := 0
+		for n < limit && pixels[i+n] == pixels[j+n] {
+			n++
+		}
+		return n
+	}
+
+	var cache [1 << vp8lCacheBits]uint32
+	cacheIndex := func(argb uint32) uint32 {
+		return (argb * 0x1e35a7bd) >> (32 - vp8lCacheBits)
+	}
+
+	var tokens []vp8lToken
+	for i := 0; i < len(pixels); {
+		bestLength, bestDistance := 0, 0
+		try := func(j int) {
+			if j < 0 || j >= i || i-j > vp8lMaxDistance || bestLength == vp8lMaxMatch {
+				return
+			}
+			if n := matchLength(i, j); n > bestLength {
+				bestLength, bestDistance = n, i-j
+			}
+		}
+		// The pixel to the left and the one above match most often
+		try(i - 1)
+		try(i - width)
+		if i+1 < len(pixels) {
+			j := head[hash(i)]
+			for depth := 0; j >= 0 && depth < vp8lChainDepth && bestLength < vp8lMaxMatch; depth++ {
+				try(int(j))
+				j = chain[j]
+			}
+		}
+
+		if bestLength >= vp8lMinMatch {
+			code, found := distanceCodes[bestDistance]
+			if !found {
+				code = bestDistance + 120
+			}
+			tokens = append(tokens, vp8lToken{kind: vp8lCopy, value: uint32(code), length: bestLength})
+			for k := i; k < i+bestLength; k++ {
+				cache[cacheIndex(pixels[k])] = pixels[k]
+				insert(k)
+			}
+			i += bestLength
+			continue
+		}
+
+		argb := pixels[i]
+		index := cacheIndex(argb)
+		if cache[index] == argb {
+			tokens = append(tokens, vp8lToken{kind: vp8lCache, value: index})
+		} else {
+			tokens = append(tokens, vp8lToken{kind: vp8lLiteral, value: argb})
+			cache[index] = argb
+		}
+		insert(i)
+		i++
+	}
+
+	return tokens
+}
+
+// vp8lPrefix splits a length or distance code (1 based) into its prefix
+// symbol and the extra bits that follow it
+func vp8lPrefix(value int) (int, uint, uint32) {
+	value--
+	if value < 4 {
+		return value, 0, 0
+	}
+	highest := 0
+	for v := value; v > 1; v >>= 1 {
+		highest++
+	}
+	second := value >> (highest - 1) & 1
+	bits := uint(highest - 1)
+	return 2*highest + second, bits, uint32(value) & (1<<bits - 1)
+}
+
+// vp8lPrefixCode is a canonical prefix code over one alphabet
+type vp8lPrefixCode struct {
+	lengths []uint8
+	codes   []uint16 // Bit reversed, ready to be written
+	symbols []int    // Symbols with a code, in ascending order
+}
+
+func newVP8LPrefixCode(histogram []int, maxLength int) vp8lPrefixCode {
+	lengths := huffmanLengths(histogram, maxLength)
+	code := vp8lPrefixCode{lengths: lengths, codes: make([]uint16, len(lengths))}
+
+	var count [16]int
+	for symbol, length := range lengths {
+		if length > 0 {
+			count[length]++
+			code.symbols = append(code.symbols, symbol)
+		}
+	}
+	var next [16]int
+	for length := 1; length < 16; length++ {
+		next[length] = (next[length-1] + count[length-1]) << 1
+	}
+	for symbol, length := range lengths {
+		if length == 0 {
+			continue
+		}
+		value := next[length]
+		next[length]++
+		var reversed uint16
+		for range length {
+			reversed = reversed<<1 | uint16(value&1)
+			value >>= 1
+		}
+		code.codes[symbol] = reversed
+	}
+
+	return code
+}
+
+// emit writes symbol. A code with a single symbol takes no bits at all.
+func (c *vp8lPrefixCode) emit(w *vp8lBitWriter, symbol int) {
+	if len(c.symbols) > 1 {
+		w.write(uint32(c.codes[symbol]), uint(c.lengths[symbol]))
+	}
+}
+
+// writeTo stores the code lengths, as a simple code where possible
+func (c *vp8lPrefixCode) writeTo(w *vp8lBitWriter) {
+	if len(c.symbols) == 0 {
+		// Unused alphabet, a simple code with only symbol 0
+		w.write(1, 1)
+		w.write(0, 1)
+		w.write(0, 1)
+		w.write(0, 1)
+		return
+	}
+	if len(c.symbols) <= 2 && c.symbols[len(c.symbols)-1] < 256 {
+		w.write(1, 1)
+		w.write(uint32(len(c.symbols)-1), 1)
+		if first := c.symbols[0]; first <= 1 {
+			w.write(0, 1)
+			w.write(uint32(first), 1)
+		} else {
+			w.write(1, 1)
+			w.write(uint32(first), 8)
+		}
+		if len(c.symbols) == 2 {
+			w.write(uint32(c.symbols[1]), 8)
+		}
+		return
+	}
+
+	w.write(0, 1)
+
+	// Run length code the lengths with 16 (repeat the previous length
+	// 3-6 times), 17 (3-10 zeros) and 18 (11-138 zeros)
+	type lengthToken struct {
+		symbol int
+		extra  uint32
+	}
+	var tokens []lengthToken
+	for i := 0; i < len(c.lengths); {
+		length := c.lengths[i]
+		run := 1
+		for i+run < len(c.lengths) && c.lengths[i+run] == length {
+			run++
+		}
+		i += run
+
+		if length == 0 {
+			for run >= 11 {
+				n := min(run, 138)
+				tokens = append(tokens, lengthToken{18, uint32(n - 11)})
+				run -= n
+			}
+			if run >= 3 {
+				tokens = append(tokens, lengthToken{17, uint32(run - 3)})
+				run = 0
+			}
+		} else {
+			tokens = append(tokens, lengthToken{int(length), 0})
+			run--
+			for run >= 3 {
+				n := min(run, 6)
+				tokens = append(tokens, lengthToken{16, uint32(n - 3)})
+				run -= n
+			}
+		}
+		for ; run > 0; run-- {
+			tokens = append(tokens, lengthToken{int(length), 0})
+		}
+	}
+
+	histogram := make([]int, 19)
+	for _, t := range tokens {
+		histogram[t.symbol]++
+	}
+	lengthCode := newVP8LPrefixCode(histogram, 7)
+
+	count := 4
+	for i, symbol := range vp8lCodeLengthOrder {
+		if lengthCode.lengths[symbol] > 0 {
+			count = max(count, i+1)
+		}
+	}
+	w.write(uint32(count-4), 4)
+	for _, symbol := range vp8lCodeLengthOrder[:count] {
+		w.write(uint32(lengthCode.lengths[symbol]), 3)
+	}
+	w.write(0, 1) // Lengths for the whole alphabet follow
+
+	extraBits := map[int]uint{16: 2, 17: 3, 18: 7}
+	for _, t := range tokens {
+		lengthCode.emit(w, t.symbol)
+		w.write(t.extra, extraBits[t.symbol])
+	}
+}
+
+// huffmanLengths returns the Huffman code length of each symbol, no longer
+// than maxLength. Unused symbols get 0, a single used symbol 1.
+func huffmanLengths(histogram []int, maxLength int) []uint8 {
+	lengths := make([]uint8, len(histogram))
+
+	type node struct {
+		weight      int
+		left, right int // Children, or -1 and the symbol for leaves
+	}
+	var leaves []node
+	for symbol, n := range histogram {
+		if n > 0 {
+			leaves = append(leaves, node{weight: n, left: -1, right: symbol})
+		}
+	}
+	switch len(leaves) {
+	case 0:
+		return lengths
+	case 1:
+		lengths[leaves[0].right] = 1
+		return lengths
+	}
+
+	for shift := 0; ; shift++ {
+		nodes := make([]node, len(leaves), 2*len(leaves)-1)
+		for i, leaf := range leaves {
+			// Flattening the weights shortens the longest codes
+			leaf.weight = max(1, histogram[leaf.right]>>shift)
+			nodes[i] = leaf
+		}
+		sort.SliceStable(nodes, func(a, b int) bool { return nodes[a].weight < nodes[b].weight })
+
+		// Two queue construction: sorted leaves and the merged nodes,
+		// which are created in ascending order of weight
+		nextLeaf, nextMerged := 0, len(leaves)
+		smallest := func() int {
+			if nextLeaf < len(leaves) && (nextMerged >= len(nodes) || nodes[nextLeaf].weight <= nodes[nextMerged].weight) {
+				nextLeaf++
+				return nextLeaf - 1
+			}
+			nextMerged++
+			return nextMerged - 1
+		}
+		for len(nodes) < 2*len(leaves)-1 {
+			a, b := smallest(), smallest()
+			nodes = append(nodes, node{weight: nodes[a].weight + nodes[b].weight, left: a, right: b})
+		}
+
+		depths := make([]int, len(nodes))
+		tooLong := false
+		for i := len(nodes) - 1; i >= 0; i-- {
+			n := nodes[i]
+			if n.left >= 0 {
+				depths[n.left] = depths[i] + 1
+				depths[n.right] = depths[i] + 1
+				continue
+			}
+			if depths[i] > maxLength {
+				tooLong = true
+				break
+			}
+			lengths[n.right] = uint8(depths[i])
+		}
+		if !tooLong {
+			return lengths
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+
+	"golang.org/x/image/webp"
+)
+
+func TestEncodeWebPRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	fill := func(width int, height int, pixel func(x, y int) color.NRGBA) *image.NRGBA {
+		img := image.NewNRGBA(image.Rect(0, 0, width, height))
+		for y := range height {
+			for x := range width {
+				img.SetNRGBA(x, y, pixel(x, y))
+			}
+		}
+		return img
+	}
+
+	tests := []struct {
+		name string
+		img  *image.NRGBA
+	}{
+		{"single pixel", fill(1, 1, func(x, y int) color.NRGBA { return color.NRGBA{R: 10, G: 20, B: 30, A: 255} })},
+		{"transparent", fill(17, 9, func(x, y int) color.NRGBA { return color.NRGBA{} })},
+		{"two colors", fill(40, 30, func(x, y int) color.NRGBA {
+			if (x/4+y/4)%2 == 0 {
+				return color.NRGBA{R: 255, A: 255}
+			}
+			return color.NRGBA{B: 255, A: 255}
+		})},
+		{"gradient with alpha", fill(300, 200, func(x, y int) color.NRGBA {
+			return color.NRGBA{R: uint8(x), G: uint8(y), B: uint8(x + y), A: uint8(255 - y)}
+		})},
+		{"noise", fill(257, 131, func(x, y int) color.NRGBA {
+			v := rng.Uint32()
+			return color.NRGBA{R: uint8(v), G: uint8(v >> 8), B: uint8(v >> 16), A: uint8(v >> 24)}
+		})},
+		{"screenshot like", fill(640, 360, func(x, y int) color.NRGBA {
+			switch {
+			case y < 24:
+				return color.NRGBA{R: 40, G: 40, B: 48, A: 255}
+			case x%97 < 3 && y%13 < 9:
+				return color.NRGBA{R: uint8(x * 7), G: uint8(y * 3), B: 200, A: 255}
+			}
+			return color.NRGBA{R: 250, G: 250, B: 250, A: 255}
+		})},
+		{"tall", fill(1, 3000, func(x, y int) color.NRGBA { return color.NRGBA{G: uint8(y / 7), A: 255} })},
+	}
+
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := EncodeWebP(&buf, tt.img); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		decoded, err := webp.Decode(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Errorf("%s: decoding failed: %v", tt.name, err)
+			continue
+		}
+		if decoded.Bounds() != tt.img.Bounds() {
+			t.Errorf("%s: got bounds %v, want %v", tt.name, decoded.Bounds(), tt.img.Bounds())
+			continue
+		}
+		mismatch := false
+		for y := 0; y < tt.img.Bounds().Dy() && !mismatch; y++ {
+			for x := 0; x < tt.img.Bounds().Dx(); x++ {
+				if got, want := color.NRGBAModel.Convert(decoded.At(x, y)), tt.img.NRGBAAt(x, y); got != want {
+					t.Errorf("%s: pixel %d,%d is %v, want %v", tt.name, x, y, got, want)
+					mismatch = true
+					break
+				}
+			}
+		}
+	}
+
+	if err := EncodeWebP(&bytes.Buffer{}, image.NewNRGBA(image.Rect(0, 0, vp8lMaxDimension+1, 1))); err == nil {
+		t.Error("expected an error for an image wider than WebP allows")
+	}
+}
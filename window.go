@@ -0,0 +1,355 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// WindowInfo describes a toplevel window on screen
+type WindowInfo struct {
+	Title    string
+	Class    string
+	Geometry Rect
+}
+
+// GetActiveWindow returns the focused window using whatever the
+// compositor or X server offers
+func GetActiveWindow(r Runner) (WindowInfo, error) {
+	if CurrentSession() == SessionX11 {
+		return x11ActiveWindow(r)
+	}
+
+	switch {
+	case os.Getenv("HYPRLAND_INSTANCE_SIGNATURE") != "" && haveBinaries(r, "hyprctl"):
+		return hyprlandActiveWindow(r)
+	case os.Getenv("SWAYSOCK") != "" && haveBinaries(r, "swaymsg"):
+		return swayActiveWindow(r)
+	case strings.Contains(strings.ToUpper(os.Getenv("XDG_CURRENT_DESKTOP")), "KDE"):
+		return kwinActiveWindow()
+	}
+
+	return WindowInfo{}, fmt.Errorf("window capture on Wayland needs Hyprland, sway or KDE Plasma")
+}
+
+// --- Hyprland
+
+func hyprlandActiveWindow(r Runner) (WindowInfo, error) {
+	output, err := r.Output("hyprctl", "activewindow", "-j")
+	if err != nil {
+		return WindowInfo{}, fmt.Errorf("hyprctl activewindow failed: %w", err)
+	}
+
+	var window struct {
+		At    []int  `json:"at"`
+		Size  []int  `json:"size"`
+		Class string `json:"class"`
+		Title string `json:"title"`
+	}
+	if err := json.Unmarshal(output, &window); err != nil {
+		return WindowInfo{}, fmt.Errorf("failed to parse hyprctl output: %w", err)
+	}
+	if len(window.At) != 2 || len(window.Size) != 2 {
+		return WindowInfo{}, fmt.Errorf("no window is focused")
+	}
+
+	return WindowInfo{
+		Title:    window.Title,
+		Class:    window.Class,
+		Geometry: Rect{X: window.At[0], Y: window.At[1], W: window.Size[0], H: window.Size[1]},
+	}, nil
+}
+
+// --- sway
+
+// swayNode is the subset of a sway tree node caplet needs
+type swayNode struct {
+	Name    string `json:"name"`
+	Focused bool   `json:"focused"`
+	Type    string `json:"type"`
+	AppID   string `json:"app_id"`
+	Rect    struct {
+		X      int `json:"x"`
+		Y      int `json:"y"`
+		Width  int `json:"width"`
+		Height int `json:"height"`
+	} `json:"rect"`
+	WindowProperties struct {
+		Class string `json:"class"`
+	} `json:"window_properties"`
+	Nodes         []swayNode `json:"nodes"`
+	FloatingNodes []swayNode `json:"floating_nodes"`
+}
+
+func (n *swayNode) findFocused() *swayNode {
+	if n.Focused {
+		return n
+	}
+	for _, children := range [][]swayNode{n.Nodes, n.FloatingNodes} {
+		for i := range children {
+			if focused := children[i].findFocused(); focused != nil {
+				return focused
+			}
+		}
+	}
+	return nil
+}
+
+func swayActiveWindow(r Runner) (WindowInfo, error) {
+	output, err := r.Output("swaymsg", "-t", "get_tree")
+	if err != nil {
+		return WindowInfo{}, fmt.Errorf("swaymsg get_tree failed: %w", err)
+	}
+
+	var tree swayNode
+	if err := json.Unmarshal(output, &tree); err != nil {
+		return WindowInfo{}, fmt.Errorf("failed to parse sway tree: %w", err)
+	}
+
+	focused := tree.findFocused()
+	if focused == nil || (focused.Type != "con" && focused.Type != "floating_con") {
+		return WindowInfo{}, fmt.Errorf("no window is focused")
+	}
+
+	// Wayland clients report app_id, Xwayland clients a class
+	class := focused.AppID
+	if class == "" {
+		class = focused.WindowProperties.Class
+	}
+
+	return WindowInfo{
+		Title: focused.Name,
+		Class: class,
+		Geometry: Rect{
+			X: focused.Rect.X,
+			Y: focused.Rect.Y,
+			W: focused.Rect.Width,
+			H: focused.Rect.Height,
+		},
+	}, nil
+}
+
+// --- KDE Plasma (KWin scripting)
+
+const kwinReportPath = "/caplet/window"
+const kwinReportInterface = "org.caplet.Window"
+
+// kwinScript reports the active window back to caplet over D-Bus.
+// KWin 6 calls it activeWindow/frameGeometry, KWin 5 activeClient/geometry.
+const kwinScript = `const w = workspace.activeWindow || workspace.activeClient;
+const g = w ? (w.frameGeometry || w.geometry) : null;
+callDBus(%q, %q, %q, "Report", JSON.stringify(w ? {
+	x: g.x, y: g.y, width: g.width, height: g.height,
+	title: w.caption, class: w.resourceClass
+} : {}));
+`
+
+func kwinActiveWindow() (WindowInfo, error) {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return WindowInfo{}, fmt.Errorf("failed to connect to session bus: %w", err)
+	}
+	defer conn.Close()
+
+	reports := make(chan string, 1)
+	err = conn.ExportMethodTable(map[string]any{
+		"Report": func(data string) *dbus.Error {
+			reports <- data
+			return nil
+		},
+	}, kwinReportPath, kwinReportInterface)
+	if err != nil {
+		return WindowInfo{}, fmt.Errorf("failed to export report method: %w", err)
+	}
+
+	scriptFile, err := os.CreateTemp("", "caplet-kwin-*.js")
+	if err != nil {
+		return WindowInfo{}, fmt.Errorf("failed to create KWin script: %w", err)
+	}
+	defer os.Remove(scriptFile.Name())
+
+	_, err = fmt.Fprintf(scriptFile, kwinScript, conn.Names()[0], kwinReportPath, kwinReportInterface)
+	scriptFile.Close()
+	if err != nil {
+		return WindowInfo{}, fmt.Errorf("failed to write KWin script: %w", err)
+	}
+
+	const pluginName = "caplet-active-window"
+	scripting := conn.Object("org.kde.KWin", "/Scripting")
+	// A leftover script from an interrupted run would block loading
+	scripting.Call("org.kde.kwin.Scripting.unloadScript", 0, pluginName)
+	defer scripting.Call("org.kde.kwin.Scripting.unloadScript", 0, pluginName)
+
+	var id int32
+	if err := scripting.Call("org.kde.kwin.Scripting.loadScript", 0, scriptFile.Name(), pluginName).Store(&id); err != nil {
+		return WindowInfo{}, fmt.Errorf("failed to load KWin script: %w", err)
+	}
+
+	// KWin 6 exposes loaded scripts below /Scripting, KWin 5 at the root
+	script := conn.Object("org.kde.KWin", dbus.ObjectPath(fmt.Sprintf("/Scripting/Script%d", id)))
+	if err := script.Call("org.kde.kwin.Script.run", 0).Err; err != nil {
+		script = conn.Object("org.kde.KWin", dbus.ObjectPath(fmt.Sprintf("/%d", id)))
+		if err := script.Call("org.kde.kwin.Script.run", 0).Err; err != nil {
+			return WindowInfo{}, fmt.Errorf("failed to run KWin script: %w", err)
+		}
+	}
+
+	var data string
+	select {
+	case data = <-reports:
+	case <-time.After(3 * time.Second):
+		return WindowInfo{}, fmt.Errorf("KWin did not report the active window")
+	}
+
+	var window struct {
+		X      float64 `json:"x"`
+		Y      float64 `json:"y"`
+		Width  float64 `json:"width"`
+		Height float64 `json:"height"`
+		Title  string  `json:"title"`
+		Class  string  `json:"class"`
+	}
+	if err := json.Unmarshal([]byte(data), &window); err != nil {
+		return WindowInfo{}, fmt.Errorf("failed to parse KWin report: %w", err)
+	}
+	if window.Width <= 0 || window.Height <= 0 {
+		return WindowInfo{}, fmt.Errorf("no window is focused")
+	}
+
+	return WindowInfo{
+		Title: window.Title,
+		Class: window.Class,
+		Geometry: Rect{
+			X: int(window.X),
+			Y: int(window.Y),
+			W: int(window.Width),
+			H: int(window.Height),
+		},
+	}, nil
+}
+
+// --- X11 (xdotool or EWMH properties)
+
+func x11ActiveWindow(r Runner) (WindowInfo, error) {
+	var id string
+	if haveBinaries(r, "xdotool") {
+		output, err := r.Output("xdotool", "getactivewindow")
+		if err != nil {
+			return WindowInfo{}, fmt.Errorf("xdotool getactivewindow failed: %w", err)
+		}
+		id = strings.TrimSpace(string(output))
+	} else if haveBinaries(r, "xprop") {
+		// _NET_ACTIVE_WINDOW(WINDOW): window id # 0x3a00007
+		output, err := r.Output("xprop", "-root", "_NET_ACTIVE_WINDOW")
+		if err != nil {
+			return WindowInfo{}, fmt.Errorf("xprop failed: %w", err)
+		}
+		fields := strings.Fields(string(output))
+		if len(fields) == 0 {
+			return WindowInfo{}, fmt.Errorf("no window is focused")
+		}
+		id = fields[len(fields)-1]
+	} else {
+		return WindowInfo{}, fmt.Errorf("window capture on X11 needs xdotool or xprop")
+	}
+
+	if id == "" || id == "0x0" {
+		return WindowInfo{}, fmt.Errorf("no window is focused")
+	}
+
+	geometry, err := x11WindowGeometry(r, id)
+	if err != nil {
+		return WindowInfo{}, err
+	}
+
+	info := WindowInfo{Geometry: geometry}
+	if haveBinaries(r, "xprop") {
+		info.Title, info.Class = x11WindowProperties(r, id)
+	}
+	if info.Title == "" && haveBinaries(r, "xdotool") {
+		if output, err := r.Output("xdotool", "getwindowname", id); err == nil {
+			info.Title = strings.TrimSpace(string(output))
+		}
+	}
+
+	return info, nil
+}
+
+func x11WindowGeometry(r Runner, id string) (Rect, error) {
+	if haveBinaries(r, "xdotool") {
+		output, err := r.Output("xdotool", "getwindowgeometry", "--shell", id)
+		if err != nil {
+			return Rect{}, fmt.Errorf("xdotool getwindowgeometry failed: %w", err)
+		}
+		values := parseKeyValues(string(output), "=")
+		return rectFromValues(values, "X", "Y", "WIDTH", "HEIGHT")
+	}
+
+	if haveBinaries(r, "xwininfo") {
+		output, err := r.Output("xwininfo", "-id", id)
+		if err != nil {
+			return Rect{}, fmt.Errorf("xwininfo failed: %w", err)
+		}
+		values := parseKeyValues(string(output), ":")
+		return rectFromValues(values, "Absolute upper-left X", "Absolute upper-left Y", "Width", "Height")
+	}
+
+	return Rect{}, fmt.Errorf("window capture on X11 needs xdotool or xwininfo")
+}
+
+// x11WindowProperties reads the EWMH title and the WM_CLASS class of a window
+func x11WindowProperties(r Runner, id string) (string, string) {
+	output, err := r.Output("xprop", "-id", id, "_NET_WM_NAME", "WM_CLASS")
+	if err != nil {
+		return "", ""
+	}
+
+	var title, class string
+	quoted := regexp.MustCompile(`"((?:[^"\\]|\\.)*)"`)
+	escaped := regexp.MustCompile(`\\(.)`)
+	for _, line := range strings.Split(string(output), "\n") {
+		values := quoted.FindAllStringSubmatch(line, -1)
+		if len(values) == 0 {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(line, "_NET_WM_NAME"):
+			title = escaped.ReplaceAllString(values[0][1], "$1")
+		case strings.HasPrefix(line, "WM_CLASS"):
+			// WM_CLASS(STRING) = "instance", "Class"
+			class = escaped.ReplaceAllString(values[len(values)-1][1], "$1")
+		}
+	}
+
+	return title, class
+}
+
+// parseKeyValues splits "key<sep>value" lines into a map
+func parseKeyValues(text string, sep string) map[string]string {
+	values := map[string]string{}
+	for _, line := range strings.Split(text, "\n") {
+		key, value, found := strings.Cut(line, sep)
+		if found {
+			values[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	return values
+}
+
+func rectFromValues(values map[string]string, keys ...string) (Rect, error) {
+	var numbers [4]int
+	for i, key := range keys {
+		n, err := strconv.Atoi(values[key])
+		if err != nil {
+			return Rect{}, fmt.Errorf("window geometry is missing %s", key)
+		}
+		numbers[i] = n
+	}
+	return Rect{X: numbers[0], Y: numbers[1], W: numbers[2], H: numbers[3]}, nil
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestHyprlandActiveWindow(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		want    WindowInfo
+		wantErr string
+	}{
+		{"focused", `{"address": "0x5e1c", "at": [-1910, 42], "size": [1280, 720], "workspace": {"id": 2}, "class": "firefox", "title": "Mozilla Firefox"}`,
+			WindowInfo{Title: "Mozilla Firefox", Class: "firefox", Geometry: Rect{X: -1910, Y: 42, W: 1280, H: 720}}, ""},
+		{"nothing focused", `{}`, WindowInfo{}, "no window is focused"},
+		{"not json", `Invalid`, WindowInfo{}, "failed to parse"},
+	}
+
+	for _, tt := range tests {
+		window, err := hyprlandActiveWindow(stubOutput(t, "hyprctl", tt.output))
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: got error %v, want it to contain %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if window != tt.want {
+			t.Errorf("%s: got %+v, want %+v", tt.name, window, tt.want)
+		}
+	}
+}
+
+func TestSwayActiveWindow(t *testing.T) {
+	// tree returns a sway layout where only the node called focused has focus
+	tree := func(focused string) string {
+		is := func(name string) string { return strconv.FormatBool(name == focused) }
+		return `{"type": "root", "name": "root", "focused": false, "nodes": [
+  {"type": "output", "name": "eDP-1", "focused": false, "nodes": [
+    {"type": "workspace", "name": "1", "focused": ` + is("1") + `, "nodes": [
+      {"type": "con", "name": "Terminal", "app_id": "foot", "focused": ` + is("Terminal") + `, "rect": {"x": 0, "y": 0, "width": 960, "height": 1080}},
+      {"type": "con", "name": "", "focused": false, "nodes": [
+        {"type": "con", "name": "Editor", "app_id": null, "window_properties": {"class": "Code"}, "focused": ` + is("Editor") + `, "rect": {"x": 960, "y": 0, "width": 960, "height": 540}}
+      ]}
+    ], "floating_nodes": [
+      {"type": "floating_con", "name": "Picture-in-Picture", "app_id": "firefox", "focused": ` + is("Picture-in-Picture") + `, "rect": {"x": 1500, "y": 700, "width": 400, "height": 225}}
+    ]}
+  ]}
+]}`
+	}
+
+	tests := []struct {
+		name    string
+		focused string
+		want    WindowInfo
+		wantErr string
+	}{
+		{"tiled", "Terminal", WindowInfo{Title: "Terminal", Class: "foot", Geometry: Rect{X: 0, Y: 0, W: 960, H: 1080}}, ""},
+		{"nested Xwayland window", "Editor", WindowInfo{Title: "Editor", Class: "Code", Geometry: Rect{X: 960, Y: 0, W: 960, H: 540}}, ""},
+		{"floating", "Picture-in-Picture", WindowInfo{Title: "Picture-in-Picture", Class: "firefox", Geometry: Rect{X: 1500, Y: 700, W: 400, H: 225}}, ""},
+		{"empty workspace", "1", WindowInfo{}, "no window is focused"},
+		{"nothing focused", "", WindowInfo{}, "no window is focused"},
+	}
+
+	for _, tt := range tests {
+		window, err := swayActiveWindow(stubOutput(t, "swaymsg", tree(tt.focused)))
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: got error %v, want it to contain %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if window != tt.want {
+			t.Errorf("%s: got %+v, want %+v", tt.name, window, tt.want)
+		}
+	}
+}
+
+func TestX11WindowGeometry(t *testing.T) {
+	tests := []struct {
+		name    string
+		binary  string
+		output  string
+		want    Rect
+		wantErr string
+	}{
+		{"xdotool", "xdotool", "WINDOW=60817415\nX=-1820\nY=64\nWIDTH=800\nHEIGHT=600\nSCREEN=0",
+			Rect{X: -1820, Y: 64, W: 800, H: 600}, ""},
+		{"xwininfo", "xwininfo", `
+xwininfo: Window id: 0x3a00007 "notes: draft"
+
+  Absolute upper-left X:  100
+  Absolute upper-left Y:  50
+  Relative upper-left X:  2
+  Relative upper-left Y:  24
+  Width: 1024
+  Height: 768
+  Depth: 24
+  Border width: 0
+  Map State: IsViewable
+  Corners:  +100+50  -796+50  -796-262  +100-262
+  -geometry 1024x768+98+26`, Rect{X: 100, Y: 50, W: 1024, H: 768}, ""},
+		{"missing field", "xdotool", "WINDOW=60817415\nX=0\nY=0\nWIDTH=800", Rect{}, "missing HEIGHT"},
+	}
+
+	for _, tt := range tests {
+		area, err := x11WindowGeometry(stubOutput(t, tt.binary, tt.output), "0x3a00007")
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: got error %v, want it to contain %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if area != tt.want {
+			t.Errorf("%s: got %+v, want %+v", tt.name, area, tt.want)
+		}
+	}
+
+	if _, err := x11WindowGeometry(stubBinaries(t), "0x3a00007"); err == nil {
+		t.Error("expected an error without xdotool or xwininfo")
+	}
+}
+
+func TestX11WindowProperties(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		title  string
+		class  string
+	}{
+		{"both", "_NET_WM_NAME(UTF8_STRING) = \"Überblick — Files\"\nWM_CLASS(STRING) = \"org.gnome.Nautilus\", \"Org.gnome.Nautilus\"",
+			"Überblick — Files", "Org.gnome.Nautilus"},
+		{"escaped quotes", `_NET_WM_NAME(UTF8_STRING) = "say \"hi\", ok \\ done"` + "\n" + `WM_CLASS(STRING) = "xterm", "XTerm"`,
+			`say "hi", ok \ done`, "XTerm"},
+		{"no title", "_NET_WM_NAME:  not found.\nWM_CLASS(STRING) = \"st-256color\", \"st-256color\"",
+			"", "st-256color"},
+		{"no properties", "_NET_WM_NAME:  not found.\nWM_CLASS:  not found.", "", ""},
+	}
+
+	for _, tt := range tests {
+		title, class := x11WindowProperties(stubOutput(t, "xprop", tt.output), "0x3a00007")
+		if title != tt.title || class != tt.class {
+			t.Errorf("%s: got %q, %q, want %q, %q", tt.name, title, class, tt.title, tt.class)
+		}
+	}
+}